@@ -4,106 +4,324 @@ import (
 	"context"
 	"net"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/streadway/amqp"
 )
 
 type AMQPPinger struct {
-	url *url.URL
+	url      *url.URL
+	password secret
+	tls      tlsOptions
+	checks   amqpChecks
+	// vhost is the virtual host in the path, which is "/" by default.
+	vhost string
+
+	heartbeat      time.Duration
+	connectionName string
 }
 
-func (p *AMQPPinger) Ping(ctx context.Context) error {
-	done := make(chan error)
-
-	go func() {
-		conn, err := amqp.DialConfig(p.url.String(), amqp.Config{
-			Dial: func(network, addr string) (net.Conn, error) {
-				dialer := &net.Dialer{}
-				if conn, err := dialer.DialContext(ctx, network, addr); err != nil {
-					return nil, err
-				} else {
-					if t, ok := ctx.Deadline(); ok {
-						conn.SetDeadline(t)
-						conn.SetReadDeadline(t)
-						conn.SetWriteDeadline(t)
-					}
-					return conn, err
-				}
-			},
-		})
-		if err != nil {
-			done <- errors.Wrap(err, "failed in connecting to AMQP server")
-			return
+// amqpChecks are the mode of an AMQP ping and its thresholds, given by query
+// parameters.
+type amqpChecks struct {
+	// mode is "publish" (default), "connect", "queue" or "exchange".
+	mode     string
+	queue    string
+	exchange string
+
+	// maxMessages is the maximum number of ready messages in the queue,
+	// checked when checkMessages is true.
+	maxMessages   int
+	checkMessages bool
+	// minConsumers is the minimum number of consumers of the queue.
+	minConsumers int
+}
+
+// parseAMQPChecks moves the mode parameters of u into amqpChecks.
+func parseAMQPChecks(u *url.URL) (amqpChecks, error) {
+	c := amqpChecks{mode: "publish"}
+
+	params := takeParams(u, "mode", "queue", "exchange", "max_messages", "min_consumers")
+	if len(params) == 0 {
+		return c, nil
+	}
+
+	c.queue = params["queue"]
+	c.exchange = params["exchange"]
+
+	// queue and exchange parameters select their modes by themselves.
+	switch {
+	case params["mode"] != "":
+		c.mode = params["mode"]
+	case c.queue != "":
+		c.mode = "queue"
+	case c.exchange != "":
+		c.mode = "exchange"
+	}
+
+	switch c.mode {
+	case "publish", "connect":
+	case "queue":
+		if c.queue == "" {
+			return c, errors.New("no queue in queue mode")
+		}
+	case "exchange":
+		if c.exchange == "" {
+			return c, errors.New("no exchange in exchange mode")
 		}
-		defer conn.Close()
+	default:
+		return c, errors.Errorf("invalid mode: %#v (must be publish, connect, queue or exchange)", c.mode)
+	}
+
+	for name, n := range map[string]*int{"max_messages": &c.maxMessages, "min_consumers": &c.minConsumers} {
+		if v, ok := params[name]; ok {
+			if c.mode != "queue" {
+				return c, errors.Errorf("%s needs queue mode", name)
+			}
 
-		ch, err := conn.Channel()
-		if err != nil {
-			done <- errors.Wrap(err, "failed in creating channel")
-			return
+			i, err := strconv.Atoi(v)
+			if err != nil || i < 0 {
+				return c, errors.Errorf("invalid %s: %#v", name, v)
+			}
+			*n = i
 		}
-		defer ch.Close()
-
-		q, err := ch.QueueDeclare(
-			"",    // name
-			false, // durable
-			true,  // autoDelete
-			true,  // exclusive
-			false, // noWait
-			nil,   // args
-		)
-		if err != nil {
-			done <- errors.Wrap(err, "failed in creating queue")
-			return
+	}
+	_, c.checkMessages = params["max_messages"]
+
+	return c, nil
+}
+
+// parseAMQPConfig moves the connection parameters of u into p, and gets the
+// vhost from the path of u.
+func parseAMQPConfig(u *url.URL, p *AMQPPinger) error {
+	// An empty path is the default vhost, and `/%2F` is also "/".
+	p.vhost = "/"
+	if len(u.Path) > 1 {
+		p.vhost = u.Path[1:]
+	}
+
+	q := u.Query()
+	if v, ok := q["heartbeat"]; ok {
+		// heartbeat is seconds in AMQP URIs, or a duration like "10s".
+		var d time.Duration
+		if n, err := strconv.Atoi(v[0]); err == nil {
+			d = time.Duration(n) * time.Second
+		} else if d, err = time.ParseDuration(v[0]); err != nil {
+			return errors.Wrapf(err, "invalid heartbeat: %#v", v[0])
 		}
-		defer ch.QueueDelete(q.Name, false, false, false)
-
-		if err := ch.Publish(
-			"",     // exchange,
-			q.Name, // key
-			false,  // mandatory
-			false,  // immediate
-			amqp.Publishing{
-				Body: []byte("png"),
-			},
-		); err != nil {
-			done <- errors.Wrap(err, "failed in publush message")
-			return
+		if d < 0 {
+			return errors.Errorf("invalid heartbeat: %#v", v[0])
 		}
+		p.heartbeat = d
+		q.Del("heartbeat")
+	}
+
+	if _, ok := q["connection_name"]; ok {
+		p.connectionName = q.Get("connection_name")
+		q.Del("connection_name")
+	}
+
+	u.RawQuery = q.Encode()
+	return nil
+}
+
+// display returns u with the mode parameters of c.
+func (c amqpChecks) display(u *url.URL) *url.URL {
+	if c.mode == "publish" || c.mode == "" {
+		return u
+	}
+
+	v := *u
+	q := v.Query()
+	q.Set("mode", c.mode)
+	if c.queue != "" {
+		q.Set("queue", c.queue)
+	}
+	if c.exchange != "" {
+		q.Set("exchange", c.exchange)
+	}
+	if c.checkMessages {
+		q.Set("max_messages", strconv.Itoa(c.maxMessages))
+	}
+	if c.minConsumers > 0 {
+		q.Set("min_consumers", strconv.Itoa(c.minConsumers))
+	}
+	v.RawQuery = q.Encode()
+	return &v
+}
 
-		wait, err := ch.Consume(
-			q.Name, // queue
-			"",     // consumer
-			true,   // autoAck
-			true,   // exclusive
-			false,  // noLocal
-			false,  // noWait
-			nil,    // args
-		)
-		if err != nil {
-			done <- errors.Wrap(err, "failed in consume message")
-			return
+func (p *AMQPPinger) String() string {
+	u := p.checks.display(p.url)
+	if p.heartbeat != 0 || p.connectionName != "" {
+		v := *u
+		q := v.Query()
+		if p.heartbeat != 0 {
+			q.Set("heartbeat", p.heartbeat.String())
 		}
+		if p.connectionName != "" {
+			q.Set("connection_name", p.connectionName)
+		}
+		v.RawQuery = q.Encode()
+		u = &v
+	}
+
+	port := "5672"
+	if u.Scheme == "amqps" {
+		port = "5671"
+	}
+	return displayURL(u, p.password, port)
+}
 
-		select {
-		case <-ctx.Done():
-			done <- errors.Wrap(err, "failed in consume message")
-		case d := <-wait:
-			if string(d.Body) == "png" {
-				done <- nil
-			} else {
-				done <- errors.Errorf("invalid AMQP response: %#v", d.Body)
+func (p *AMQPPinger) Ping(ctx context.Context) error {
+	u, password, err := p.password.userURL(p.url)
+	if err != nil {
+		return errors.Wrap(err, "failed in connecting to AMQP server")
+	}
+
+	fail := func(err error, msg string) error {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "failed in AMQP connection")
+		}
+		return redact(errors.Wrap(err, msg), password)
+	}
+
+	// TLS of amqps:// is done by p.tls, not by the AMQP client.
+	if u.Scheme == "amqps" {
+		v := *u
+		v.Scheme = "amqp"
+		u = &v
+	}
+
+	properties := amqp.Table{"product": "png"}
+	if p.connectionName != "" {
+		properties["connection_name"] = p.connectionName
+	}
+
+	stop := func() {}
+	conn, err := amqp.DialConfig(u.String(), amqp.Config{
+		Vhost:      p.vhost,
+		Heartbeat:  p.heartbeat,
+		Properties: properties,
+		Dial: func(network, addr string) (net.Conn, error) {
+			conn, err := p.tls.dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
 			}
+
+			stop = closeOnDone(ctx, conn)
+			return conn, nil
+		},
+	})
+	defer func() { stop() }()
+	if err != nil {
+		return fail(err, "failed in connecting to AMQP server")
+	}
+	defer conn.Close()
+
+	for _, name := range []string{"product", "version", "cluster_name"} {
+		if v, ok := conn.Properties[name].(string); ok {
+			report(ctx, "amqp_"+name, v)
 		}
+	}
+
+	if p.checks.mode == "connect" {
+		return nil
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fail(err, "failed in creating channel")
+	}
+	defer ch.Close()
 
-		return
-	}()
+	switch p.checks.mode {
+	case "queue":
+		return p.checkQueue(ctx, ch, fail)
+	case "exchange":
+		// The type of an exchange is ignored by a passive declaration.
+		if err := ch.ExchangeDeclarePassive(p.checks.exchange, "direct", false, false, false, false, nil); err != nil {
+			return fail(err, "failed in checking exchange")
+		}
+		return nil
+	}
+
+	return p.publish(ctx, ch, fail)
+}
+
+// checkQueue declares the queue passively, which fails when it does not
+// exist, and checks its message and consumer counts.
+func (p *AMQPPinger) checkQueue(ctx context.Context, ch *amqp.Channel, fail func(error, string) error) error {
+	q, err := ch.QueueDeclarePassive(p.checks.queue, false, false, false, false, nil)
+	if err != nil {
+		return fail(err, "failed in checking queue")
+	}
+
+	report(ctx, "amqp_queue_messages", strconv.Itoa(q.Messages))
+	report(ctx, "amqp_queue_consumers", strconv.Itoa(q.Consumers))
+
+	if p.checks.checkMessages && q.Messages > p.checks.maxMessages {
+		return errors.Errorf("queue %#v has %d messages (more than %d)", q.Name, q.Messages, p.checks.maxMessages)
+	}
+	if q.Consumers < p.checks.minConsumers {
+		return errors.Errorf("queue %#v has %d consumers (less than %d)", q.Name, q.Consumers, p.checks.minConsumers)
+	}
+	return nil
+}
+
+// publish publishes a message to a temporary queue, and consumes it.
+func (p *AMQPPinger) publish(ctx context.Context, ch *amqp.Channel, fail func(error, string) error) error {
+	q, err := ch.QueueDeclare(
+		"",    // name
+		false, // durable
+		true,  // autoDelete
+		true,  // exclusive
+		false, // noWait
+		nil,   // args
+	)
+	if err != nil {
+		return fail(err, "failed in creating queue")
+	}
+	defer ch.QueueDelete(q.Name, false, false, false)
+
+	if err := ch.Publish(
+		"",     // exchange,
+		q.Name, // key
+		false,  // mandatory
+		false,  // immediate
+		amqp.Publishing{
+			Body: []byte("png"),
+		},
+	); err != nil {
+		return fail(err, "failed in publush message")
+	}
+
+	wait, err := ch.Consume(
+		q.Name, // queue
+		"",     // consumer
+		true,   // autoAck
+		true,   // exclusive
+		false,  // noLocal
+		false,  // noWait
+		nil,    // args
+	)
+	if err != nil {
+		return fail(err, "failed in consume message")
+	}
 
 	select {
 	case <-ctx.Done():
 		return errors.Wrap(ctx.Err(), "failed in AMQP connection")
-	case err := <-done:
-		return err
+	case d, ok := <-wait:
+		if !ok {
+			return fail(errors.New("channel is closed"), "failed in consume message")
+		}
+
+		if string(d.Body) != "png" {
+			return errors.Errorf("invalid AMQP response: %#v", d.Body)
+		}
 	}
+
+	return nil
 }
@@ -0,0 +1,227 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"strings"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestAMQPPingerPing(t *testing.T) {
+	ping := func(s *pngtest.AMQPServer, params string) (*Report, error) {
+		p, err := Parse(s.URL + params)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, r := WithReport(context.Background())
+		return r, p.Ping(ctx)
+	}
+
+	t.Run("Publish", func(t *testing.T) {
+		s := pngtest.NewAMQPServer()
+		defer s.Close()
+
+		if _, err := ping(s, ""); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+	})
+
+	t.Run("Connect", func(t *testing.T) {
+		s := pngtest.NewAMQPServer()
+		defer s.Close()
+
+		if _, err := ping(s, "?mode=connect"); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+	})
+
+	t.Run("Queue", func(t *testing.T) {
+		s := pngtest.NewAMQPServer()
+		defer s.Close()
+
+		s.SetQueue("jobs", pngtest.AMQPQueue{Messages: 10, Consumers: 2})
+
+		r, err := ping(s, "?queue=jobs&max_messages=10&min_consumers=1")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+		details := make(map[string]string)
+		for _, d := range r.Details() {
+			details[d.Name] = d.Value
+		}
+		if details["amqp_queue_messages"] != "10" || details["amqp_queue_consumers"] != "2" {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+
+		for _, c := range []struct {
+			params, msg string
+		}{
+			{"?queue=jobs&max_messages=5", "queue \"jobs\" has 10 messages (more than 5)"},
+			{"?queue=jobs&min_consumers=3", "queue \"jobs\" has 2 consumers (less than 3)"},
+		} {
+			_, err := ping(s, c.params)
+			if err == nil {
+				t.Fatalf("succeeded to ping with %s", c.params)
+			}
+			if msg := err.Error(); msg != c.msg {
+				t.Fatalf("unexpected error message: %#v", msg)
+			}
+		}
+
+		_, err = ping(s, "?queue=unknown")
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); !strings.HasPrefix(msg, "failed in checking queue: ") || !strings.Contains(msg, "NOT_FOUND") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Exchange", func(t *testing.T) {
+		s := pngtest.NewAMQPServer()
+		defer s.Close()
+
+		s.AddExchange("events")
+
+		if _, err := ping(s, "?exchange=events"); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		_, err := ping(s, "?mode=exchange&exchange=unknown")
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); !strings.HasPrefix(msg, "failed in checking exchange: ") || !strings.Contains(msg, "NOT_FOUND") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
+
+func TestAMQPPingerConfig(t *testing.T) {
+	t.Run("TLS", func(t *testing.T) {
+		ca := pngtest.NewCA()
+		s := pngtest.NewAMQPServer()
+		defer s.Close()
+		s.SetTLS(ca.ServerConfig(ca.Issue("127.0.0.1"), false))
+
+		p, err := Parse(strings.Replace(s.URL, "amqp://", "amqps://", 1) + "?tls_insecure=true&tls_report=true&mode=connect")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, r := WithReport(context.Background())
+		if err := p.Ping(ctx); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		details := make(map[string]string)
+		for _, d := range r.Details() {
+			details[d.Name] = d.Value
+		}
+		if details["amqp_product"] != "pngtest" || details["amqp_version"] != "0.0.0" || details["tls_subject"] == "" {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+	})
+
+	t.Run("Vhost", func(t *testing.T) {
+		s := pngtest.NewAMQPServer()
+		defer s.Close()
+		s.AddVhost("png")
+
+		p, err := Parse(s.URL + "png?heartbeat=5&connection_name=checker")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		c := s.LastClient()
+		if c.Vhost != "png" || c.Heartbeat != 5 || c.Properties["connection_name"] != "checker" {
+			t.Fatalf("unexpected client: %+#v", c)
+		}
+
+		p, err = Parse(s.URL + "unknown")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); !strings.HasPrefix(msg, "failed in connecting to AMQP server: ") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
+
+func TestParseAMQPConfig(t *testing.T) {
+	for _, c := range []struct {
+		rawurl, vhost string
+	}{
+		{"amqp://localhost", "/"},
+		{"amqp://localhost/", "/"},
+		{"amqp://localhost/%2F", "/"},
+		{"amqp://localhost/png", "png"},
+		{"amqps://localhost/a%2Fb", "a/b"},
+	} {
+		p, err := Parse(c.rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if vhost := p.(*AMQPPinger).vhost; vhost != c.vhost {
+			t.Fatalf("unexpected vhost of %#v: %#v", c.rawurl, vhost)
+		}
+	}
+
+	p, err := Parse("amqps://localhost/?heartbeat=10s&connection_name=png")
+	if err != nil {
+		t.Fatalf("failed in Parse(): %+#v", err)
+	}
+
+	if s := Describe(p); s != "amqps://localhost:5671/?connection_name=png&heartbeat=10s" {
+		t.Fatalf("unexpected display form: %#v", s)
+	}
+
+	if _, err := Parse("amqp://localhost/?heartbeat=soon"); err == nil || !strings.HasPrefix(err.Error(), "invalid heartbeat: \"soon\"") {
+		t.Fatalf("unexpected error: %+#v", err)
+	}
+}
+
+func TestParseAMQPChecks(t *testing.T) {
+	t.Run("Display", func(t *testing.T) {
+		p, err := Parse("amqp://localhost/?queue=jobs&max_messages=100")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if s := Describe(p); s != "amqp://localhost:5672/?max_messages=100&mode=queue&queue=jobs" {
+			t.Fatalf("unexpected display form: %#v", s)
+		}
+	})
+
+	for _, c := range []struct {
+		rawurl, msg string
+	}{
+		{"amqp://localhost/?mode=queue", "no queue in queue mode"},
+		{"amqp://localhost/?mode=exchange", "no exchange in exchange mode"},
+		{"amqp://localhost/?mode=consume", "invalid mode: \"consume\" (must be publish, connect, queue or exchange)"},
+		{"amqp://localhost/?max_messages=1", "max_messages needs queue mode"},
+		{"amqp://localhost/?queue=jobs&max_messages=-1", "invalid max_messages: \"-1\""},
+	} {
+		p, err := Parse(c.rawurl)
+		if err == nil {
+			t.Fatalf("succeeded in Parse(): %+#v", p)
+		}
+
+		if msg := err.Error(); msg != c.msg {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	}
+}
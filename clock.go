@@ -0,0 +1,96 @@
+package png
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock is a source of time. It can be replaced to test timing without
+// real sleeps.
+type Clock interface {
+	Now() time.Time
+
+	// Sleep waits for d, or returns ctx.Err() when ctx is done before that.
+	Sleep(ctx context.Context, d time.Duration) error
+
+	// AfterFunc calls f after d. stop cancels the call, and reports whether
+	// it is cancelled before f is called.
+	AfterFunc(d time.Duration, f func()) (stop func() bool)
+}
+
+// SystemClock is the Clock of the real time.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) func() bool {
+	return time.AfterFunc(d, f).Stop
+}
+
+// TimeoutContext is like context.WithTimeout, but its deadline is measured
+// by clock.
+func TimeoutContext(parent context.Context, clock Clock, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if clock == SystemClock {
+		return context.WithTimeout(parent, timeout)
+	}
+
+	ctx, cancel := context.WithCancel(parent)
+	c := &clockContext{Context: ctx, deadline: clock.Now().Add(timeout)}
+	stop := clock.AfterFunc(timeout, func() {
+		c.expire()
+		cancel()
+	})
+
+	return c, func() {
+		stop()
+		cancel()
+	}
+}
+
+type clockContext struct {
+	context.Context
+	deadline time.Time
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func (c *clockContext) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Context.Err() == nil {
+		c.expired = true
+	}
+}
+
+func (c *clockContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.expired {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
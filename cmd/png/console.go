@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/MakeNowJust/png"
 	"github.com/fatih/color"
 )
 
@@ -14,6 +15,7 @@ var (
 	okColor      = color.New(color.FgGreen).SprintfFunc()
 	timeoutColor = color.New(color.FgYellow).SprintfFunc()
 	errorColor   = color.New(color.FgHiRed).SprintfFunc()
+	blockedColor = color.New(color.FgMagenta).SprintfFunc()
 
 	elapsedColor = color.New(color.FgHiBlack).SprintFunc()
 )
@@ -32,7 +34,7 @@ func (r *runner) HookConsole() {
 		fmt.Printf("%s %s ", targetColor(targetFmt, target), arrowColor("->"))
 	}
 
-	r.hookPingAfter = func(target, status string, elapsed time.Duration, err error) {
+	r.hookPingAfter = func(target, status string, elapsed time.Duration, err error, report *png.Report) {
 		padStatus := fmt.Sprintf("%-7s", status)
 
 		switch status {
@@ -42,6 +44,15 @@ func (r *runner) HookConsole() {
 			fmt.Printf("%s %s\n", timeoutColor(padStatus), elapsedColor(elapsed))
 		case "error":
 			fmt.Printf("%s %s\n  %v\n", errorColor(padStatus), elapsedColor(elapsed), err)
+		case "blocked":
+			fmt.Printf("%s\n  %v\n", blockedColor(padStatus), err)
+		}
+
+		for _, warning := range report.Warnings() {
+			fmt.Printf("  %s\n", timeoutColor("warning: %s", warning))
+		}
+		for _, detail := range report.Details() {
+			fmt.Printf("  %s\n", elapsedColor(detail.Name+": "+detail.Value))
 		}
 	}
 
@@ -49,19 +60,26 @@ func (r *runner) HookConsole() {
 		fmt.Println()
 	}
 
-	r.hookStats = func(target string, ok, timeout, error, total int, min, max, average time.Duration) {
+	r.hookStats = func(target string, ok, timeout, error, blocked, total int, min, max, average time.Duration) {
 		color := okColor
 		if ok != total {
-			if timeout > error {
+			if blocked > timeout && blocked > error {
+				color = blockedColor
+			} else if timeout > error {
 				color = timeoutColor
 			} else {
 				color = errorColor
 			}
 		}
 
+		// blocked is shown only with dependencies, to keep the stats of the
+		// other runs as they were.
+		counts := color("ok/timeout/error/total = %2d/%2d/%2d/%2d", ok, timeout, error, total)
+		if r.hasDepends() {
+			counts = color("ok/timeout/error/blocked/total = %2d/%2d/%2d/%2d/%2d", ok, timeout, error, blocked, total)
+		}
+
 		fmt.Printf("%s: %s, min/max/average = %12s/%12s/%12s\n",
-			targetColor(targetFmt, target),
-			color("ok/timeout/error/total = %2d/%2d/%2d/%2d", ok, timeout, error, total),
-			min, max, average)
+			targetColor(targetFmt, target), counts, min, max, average)
 	}
 }
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// userinfoPassword and paramPassword match passwords of targets in a
+	// dependency, in userinfo and in query parameters like
+	// sentinel_password.
+	userinfoPassword = regexp.MustCompile(`(://[^/?#@:]*:)[^/?#@]*@`)
+	paramPassword    = regexp.MustCompile(`([?&]\w*password=)[^&]*`)
+)
+
+// maskDepend hides passwords in spec, in the same form as the display form
+// of targets.
+func maskDepend(spec string) string {
+	spec = userinfoPassword.ReplaceAllString(spec, "${1}xxxxx@")
+	return paramPassword.ReplaceAllString(spec, "${1}xxxxx")
+}
+
+// parseDepends parses `DEPENDENT=PARENT` specs, and returns the parents of
+// each target. Each side is a target as given, or its 1-based index.
+func parseDepends(specs, targets []string) ([][]int, error) {
+	resolve := func(s string) (int, bool) {
+		for i, target := range targets {
+			if s == target {
+				return i, true
+			}
+		}
+
+		if n, err := strconv.Atoi(s); err == nil && 1 <= n && n <= len(targets) {
+			return n - 1, true
+		}
+		return 0, false
+	}
+
+	parents := make([][]int, len(targets))
+	for _, spec := range specs {
+		// Targets may contain `=` in their query, so it tries every `=`.
+		found := false
+		for i := strings.Index(spec, "="); i >= 0 && !found; {
+			dependent, ok1 := resolve(spec[:i])
+			parent, ok2 := resolve(spec[i+1:])
+			if ok1 && ok2 {
+				parents[dependent] = append(parents[dependent], parent)
+				found = true
+			}
+
+			if j := strings.Index(spec[i+1:], "="); j >= 0 {
+				i += j + 1
+			} else {
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("invalid dependency: %s (must be DEPENDENT=PARENT of targets)", maskDepend(spec))
+		}
+	}
+
+	return parents, nil
+}
+
+// sortTargets returns the indices of targets in the order that parents come
+// before their dependents, keeping the given order otherwise.
+func sortTargets(targets []string, parents [][]int) ([]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	order := make([]int, 0, len(parents))
+	states := make([]int, len(parents))
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch states[i] {
+		case visiting:
+			return fmt.Errorf("circular dependency on %s", targets[i])
+		case visited:
+			return nil
+		}
+
+		states[i] = visiting
+		for _, parent := range parents[i] {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		states[i] = visited
+
+		order = append(order, i)
+		return nil
+	}
+
+	for i := range parents {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
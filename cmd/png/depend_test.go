@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+
+	"reflect"
+)
+
+func TestParseDepends(t *testing.T) {
+	targets := []string{"http://example.com", "redis://localhost?db=1", "tcp://localhost:80"}
+
+	t.Run("OK", func(t *testing.T) {
+		parents, err := parseDepends([]string{"1=tcp://localhost:80", "redis://localhost?db=1=3"}, targets)
+		if err != nil {
+			t.Fatalf("failed in parseDepends(): %+#v", err)
+		}
+
+		if want := [][]int{{2}, {2}, nil}; !reflect.DeepEqual(parents, want) {
+			t.Fatalf("unexpected parents: %#v", parents)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, spec := range []string{"1", "1=4", "unknown=1"} {
+			_, err := parseDepends([]string{spec}, targets)
+			if err == nil {
+				t.Fatalf("succeeded in parseDepends(): %#v", spec)
+			}
+
+			if msg := err.Error(); msg != "invalid dependency: "+spec+" (must be DEPENDENT=PARENT of targets)" {
+				t.Fatalf("unexpected error message: %#v", msg)
+			}
+		}
+	})
+
+	t.Run("Password", func(t *testing.T) {
+		_, err := parseDepends([]string{"redis://:secret@localhost=redis-sentinel://s1?master=m&sentinel_password=secret"}, targets)
+		if err == nil {
+			t.Fatal("succeeded in parseDepends()")
+		}
+
+		if msg := err.Error(); msg != "invalid dependency: redis://:xxxxx@localhost=redis-sentinel://s1?master=m&sentinel_password=xxxxx (must be DEPENDENT=PARENT of targets)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
+
+func TestSortTargets(t *testing.T) {
+	targets := []string{"a", "b", "c"}
+
+	t.Run("OK", func(t *testing.T) {
+		order, err := sortTargets(targets, [][]int{{2}, nil, {1}})
+		if err != nil {
+			t.Fatalf("failed in sortTargets(): %+#v", err)
+		}
+
+		if want := []int{1, 2, 0}; !reflect.DeepEqual(order, want) {
+			t.Fatalf("unexpected order: %#v", order)
+		}
+	})
+
+	t.Run("Circular", func(t *testing.T) {
+		_, err := sortTargets(targets, [][]int{{2}, nil, {0}})
+		if err == nil {
+			t.Fatal("succeeded in sortTargets()")
+		}
+
+		if msg := err.Error(); msg != "circular dependency on a" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
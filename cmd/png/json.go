@@ -6,6 +6,8 @@ import (
 	"log"
 	"os"
 	"time"
+
+	"github.com/MakeNowJust/png"
 )
 
 type result struct {
@@ -14,10 +16,12 @@ type result struct {
 }
 
 type ping struct {
-	Target  string        `json:"target"`
-	Status  string        `json:"status"`
-	Elapsed time.Duration `json:"elapsed"`
-	Err     string        `json:"err,omitempty"`
+	Target   string        `json:"target"`
+	Status   string        `json:"status"`
+	Elapsed  time.Duration `json:"elapsed"`
+	Err      string        `json:"err,omitempty"`
+	Details  []png.Detail  `json:"details,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
 }
 
 type stats struct {
@@ -25,6 +29,7 @@ type stats struct {
 	Ok      int           `json:"ok"`
 	Timeout int           `json:"timeout"`
 	Error   int           `json:"error"`
+	Blocked int           `json:"blocked"`
 	Total   int           `json:"total"`
 	Min     time.Duration `json:"min"`
 	Max     time.Duration `json:"max"`
@@ -42,14 +47,16 @@ func (r *runner) HookJSON() {
 	r.hookPingBefore = func(target string) {}
 	r.hookStatsBefore = func() {}
 
-	r.hookPingAfter = func(target, status string, elapsed time.Duration, err error) {
+	r.hookPingAfter = func(target, status string, elapsed time.Duration, err error, report *png.Report) {
 		json, err := json.Marshal(&result{
 			Type: "ping",
 			Payload: &ping{
-				Target:  target,
-				Status:  status,
-				Elapsed: elapsed,
-				Err:     errString(err),
+				Target:   target,
+				Status:   status,
+				Elapsed:  elapsed,
+				Err:      errString(err),
+				Details:  report.Details(),
+				Warnings: report.Warnings(),
 			},
 		})
 		if err != nil {
@@ -59,7 +66,7 @@ func (r *runner) HookJSON() {
 		fmt.Println()
 	}
 
-	r.hookStats = func(target string, ok, timeout, error, total int, min, max, average time.Duration) {
+	r.hookStats = func(target string, ok, timeout, error, blocked, total int, min, max, average time.Duration) {
 		json, err := json.Marshal(&result{
 			Type: "stats",
 			Payload: &stats{
@@ -67,6 +74,7 @@ func (r *runner) HookJSON() {
 				Ok:      ok,
 				Timeout: timeout,
 				Error:   error,
+				Blocked: blocked,
 				Total:   total,
 				Min:     min,
 				Max:     max,
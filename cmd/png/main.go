@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/MakeNowJust/png"
@@ -18,6 +20,13 @@ func main() {
 	noColor := flag.BoolP("no-color", "C", false, "disable color output")
 	stats := flag.StringP("stats", "s", "", "decide to show statistics (default all; all/only/none)")
 	format := flag.StringP("format", "f", "", "output format (default console; console/json)")
+	flag.String("tls-ca", "", "CA certificate file to verify servers (tls_ca)")
+	flag.String("tls-cert", "", "client certificate file (tls_cert)")
+	flag.String("tls-key", "", "client private key file (tls_key)")
+	flag.String("tls-server-name", "", "server name to verify servers (tls_server_name)")
+	flag.String("tls-min-version", "", "minimum TLS version; 1.0/1.1/1.2/1.3 (tls_min_version)")
+	flag.Bool("tls-insecure", false, "skip verification of servers (tls_insecure)")
+	depends := flag.StringArrayP("depend", "d", nil, "skip DEPENDENT while PARENT is down (DEPENDENT=PARENT; target or 1-based index)")
 
 	flag.Parse()
 	color.NoColor = *noColor
@@ -47,23 +56,48 @@ func main() {
 		os.Exit(1)
 	}
 
-	targets := flag.Args()
-	pingers := make([]png.Pinger, len(targets))
-	for i, target := range targets {
-		pinger, err := png.Parse(target)
+	// TLS flags give the defaults of the query parameters of targets using
+	// TLS, so that only the given flags are used.
+	defaults := url.Values{}
+	flag.Visit(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "tls-") {
+			defaults.Set(strings.Replace(f.Name, "-", "_", -1), f.Value.String())
+		}
+	})
+
+	rawTargets := flag.Args()
+	targets := make([]string, len(rawTargets))
+	pingers := make([]png.Pinger, len(rawTargets))
+	for i, target := range rawTargets {
+		pinger, err := png.ParseWithDefaults(target, defaults)
 		if err != nil {
 			log.Fatal(err)
 		}
 		pingers[i] = pinger
+		// Shows targets in the display form, not to print passwords.
+		targets[i] = png.Describe(pinger)
+	}
+
+	parents, err := parseDepends(*depends, rawTargets)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	order, err := sortTargets(targets, parents)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	runner := &runner{
+		clock:    png.SystemClock,
 		count:    *count,
 		timeout:  *timeout,
 		interval: *interval,
 		stats:    *stats,
 		targets:  targets,
 		pingers:  pingers,
+		parents:  parents,
+		order:    order,
 	}
 
 	switch *format {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"math"
 	"time"
 
@@ -16,41 +17,68 @@ func (t *timeoutError) Error() string {
 	return t.Err.Error()
 }
 
-func pingWithTimeout(p png.Pinger, timeout time.Duration) (elapsed time.Duration, err error) {
-	start := time.Now()
+func pingWithTimeout(p png.Pinger, clock png.Clock, timeout time.Duration) (elapsed time.Duration, report *png.Report, err error) {
+	start := clock.Now()
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := png.TimeoutContext(context.Background(), clock, timeout)
 	defer cancel()
+	ctx, report = png.WithReport(ctx)
 
-	done := make(chan error)
-	go func() {
-		done <- p.Ping(ctx)
-	}()
+	// Pingers return promptly on cancellation, so it is not needed to wait
+	// on another goroutine.
+	err = p.Ping(ctx)
+	elapsed = clock.Now().Sub(start)
 
-	select {
-	case <-ctx.Done():
-		elapsed = time.Since(start)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
 		err = &timeoutError{Err: ctx.Err()}
-	case err = <-done:
-		elapsed = time.Since(start)
 	}
 
 	return
 }
 
 type runner struct {
+	clock    png.Clock
 	count    int
 	timeout  time.Duration
 	interval time.Duration
 	stats    string
 
 	hookPingBefore  func(target string)
-	hookPingAfter   func(target, status string, elapsed time.Duration, err error)
+	hookPingAfter   func(target, status string, elapsed time.Duration, err error, report *png.Report)
 	hookStatsBefore func()
-	hookStats       func(target string, ok, timeout, error, total int, min, max, average time.Duration)
+	hookStats       func(target string, ok, timeout, error, blocked, total int, min, max, average time.Duration)
 
 	targets []string
 	pingers []png.Pinger
+
+	// parents are the indices of targets each target depends on, and order
+	// is the order of pings, in which parents come before their dependents.
+	parents [][]int
+	order   []int
+}
+
+// hasDepends reports whether any target depends on another.
+func (r *runner) hasDepends() bool {
+	for _, parents := range r.parents {
+		if len(parents) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedBy returns the parent of target i which is down in this round.
+func (r *runner) blockedBy(i int, statuses []string) (int, bool) {
+	if r.parents == nil {
+		return 0, false
+	}
+
+	for _, parent := range r.parents[i] {
+		if statuses[parent] != "ok" {
+			return parent, true
+		}
+	}
+	return 0, false
 }
 
 func (r *runner) Run() {
@@ -59,17 +87,32 @@ func (r *runner) Run() {
 
 	for i := 0; r.count == 0 || i < r.count; i++ {
 		if i != 0 {
-			time.Sleep(r.interval)
+			r.clock.Sleep(context.Background(), r.interval)
 		}
 
-		for i, p := range r.pingers {
+		order := r.order
+		if order == nil {
+			order = make([]int, len(r.pingers))
+			for i := range order {
+				order[i] = i
+			}
+		}
+
+		statuses := make([]string, len(r.pingers))
+		for _, i := range order {
 			if r.stats != "only" {
 				r.hookPingBefore(r.targets[i])
 			}
 
-			elapsed, err := pingWithTimeout(p, r.timeout)
+			var elapsed time.Duration
+			var report *png.Report
+			var err error
 			var status string
-			if err == nil {
+			if parent, ok := r.blockedBy(i, statuses); ok {
+				// Dependents of a target which is down are not pinged.
+				status = "blocked"
+				err = fmt.Errorf("blocked by %s", r.targets[parent])
+			} else if elapsed, report, err = pingWithTimeout(r.pingers[i], r.clock, r.timeout); err == nil {
 				status = "ok"
 			} else {
 				if to, ok := err.(*timeoutError); ok {
@@ -81,8 +124,9 @@ func (r *runner) Run() {
 			}
 
 			if r.stats != "only" {
-				r.hookPingAfter(r.targets[i], status, elapsed, err)
+				r.hookPingAfter(r.targets[i], status, elapsed, err, report)
 			}
+			statuses[i] = status
 			results[i] = append(results[i], status)
 			durations[i] = append(durations[i], elapsed)
 		}
@@ -101,12 +145,9 @@ func (r *runner) Run() {
 		ok := 0
 		timeout := 0
 		error := 0
+		blocked := 0
 
-		min := time.Duration(math.MaxInt64)
-		max := time.Duration(0)
-		average := time.Duration(0)
-
-		for j, result := range results[i] {
+		for _, result := range results[i] {
 			switch result {
 			case "ok":
 				ok += 1
@@ -114,6 +155,25 @@ func (r *runner) Run() {
 				timeout += 1
 			case "error":
 				error += 1
+			case "blocked":
+				blocked += 1
+			}
+		}
+
+		// Blocked targets are not pinged, so they have no elapsed time.
+		pinged := total - blocked
+
+		min := time.Duration(math.MaxInt64)
+		max := time.Duration(0)
+		average := time.Duration(0)
+
+		if pinged == 0 {
+			min = 0
+		}
+
+		for j, result := range results[i] {
+			if result == "blocked" {
+				continue
 			}
 
 			elapsed := durations[i][j]
@@ -126,11 +186,11 @@ func (r *runner) Run() {
 				max = elapsed
 			}
 
-			average += elapsed / time.Duration(total)
+			average += elapsed / time.Duration(pinged)
 		}
 
 		if r.stats != "none" {
-			r.hookStats(target, ok, timeout, error, total, min, max, average)
+			r.hookStats(target, ok, timeout, error, blocked, total, min, max, average)
 		}
 	}
 }
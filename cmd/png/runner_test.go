@@ -0,0 +1,146 @@
+package main
+
+import (
+	"testing"
+
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MakeNowJust/png"
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+type recorder struct {
+	pings []string
+	stats []string
+}
+
+func (rec *recorder) hook(r *runner) {
+	r.hookPingBefore = func(target string) {}
+	r.hookStatsBefore = func() {}
+
+	r.hookPingAfter = func(target, status string, elapsed time.Duration, err error, report *png.Report) {
+		rec.pings = append(rec.pings, fmt.Sprintf("%s %s %v %v", target, status, elapsed, err))
+	}
+
+	r.hookStats = func(target string, ok, timeout, error, blocked, total int, min, max, average time.Duration) {
+		rec.stats = append(rec.stats, fmt.Sprintf("%s %d/%d/%d/%d/%d %v/%v/%v", target, ok, timeout, error, blocked, total, min, max, average))
+	}
+}
+
+func newTestRunner(clock png.Clock, stats string) (*runner, *recorder) {
+	refused := errors.New("connection refused")
+
+	r := &runner{
+		clock:    clock,
+		count:    3,
+		timeout:  100 * time.Millisecond,
+		interval: time.Second,
+		stats:    stats,
+		targets:  []string{"a", "b"},
+		pingers: []png.Pinger{
+			png.NewFakePinger(clock, png.FakeOK(10*time.Millisecond), png.FakeOK(30*time.Millisecond), png.FakeTimeout()),
+			png.NewFakePinger(clock, png.FakeError(5*time.Millisecond, refused), png.FakeOK(20*time.Millisecond)),
+		},
+	}
+
+	rec := &recorder{}
+	rec.hook(r)
+	return r, rec
+}
+
+func checkLines(t *testing.T, name string, lines, want []string) {
+	if len(lines) != len(want) {
+		t.Fatalf("unexpected %s: %#v", name, lines)
+	}
+
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Fatalf("unexpected %s at %d: %#v", name, i, lines[i])
+		}
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	t.Run("All", func(t *testing.T) {
+		clock := pngtest.NewFakeClock()
+		start := clock.Now()
+		r, rec := newTestRunner(clock, "all")
+		r.Run()
+
+		checkLines(t, "pings", rec.pings, []string{
+			"a ok 10ms <nil>",
+			"b error 5ms connection refused",
+			"a ok 30ms <nil>",
+			"b ok 20ms <nil>",
+			"a timeout 100ms context deadline exceeded",
+			"b error 5ms connection refused",
+		})
+
+		checkLines(t, "stats", rec.stats, []string{
+			"a 2/1/0/0/3 10ms/100ms/46.666666ms",
+			"b 1/0/2/0/3 5ms/20ms/9.999998ms",
+		})
+
+		if elapsed := clock.Now().Sub(start); elapsed != 2*time.Second+170*time.Millisecond {
+			t.Fatalf("unexpected elapsed time: %v", elapsed)
+		}
+	})
+
+	t.Run("Only", func(t *testing.T) {
+		r, rec := newTestRunner(pngtest.NewFakeClock(), "only")
+		r.Run()
+
+		checkLines(t, "pings", rec.pings, nil)
+		if len(rec.stats) != 2 {
+			t.Fatalf("unexpected stats: %#v", rec.stats)
+		}
+	})
+
+	t.Run("None", func(t *testing.T) {
+		r, rec := newTestRunner(pngtest.NewFakeClock(), "none")
+		r.Run()
+
+		if len(rec.pings) != 6 {
+			t.Fatalf("unexpected pings: %#v", rec.pings)
+		}
+		checkLines(t, "stats", rec.stats, nil)
+	})
+}
+
+func TestRunnerRunDepend(t *testing.T) {
+	r, rec := newTestRunner(pngtest.NewFakeClock(), "all")
+	// a depends on b, so b is pinged first.
+	r.parents = [][]int{{1}, nil}
+	r.order = []int{1, 0}
+	r.Run()
+
+	checkLines(t, "pings", rec.pings, []string{
+		"b error 5ms connection refused",
+		"a blocked 0s blocked by b",
+		"b ok 20ms <nil>",
+		"a ok 10ms <nil>",
+		"b error 5ms connection refused",
+		"a blocked 0s blocked by b",
+	})
+
+	checkLines(t, "stats", rec.stats, []string{
+		"a 1/0/0/2/3 10ms/10ms/10ms",
+		"b 1/0/2/0/3 5ms/20ms/9.999998ms",
+	})
+}
+
+func TestPingWithTimeout(t *testing.T) {
+	clock := pngtest.NewFakeClock()
+	p := png.NewFakePinger(clock, png.FakeTimeout())
+
+	elapsed, _, err := pingWithTimeout(p, clock, time.Minute)
+	if _, ok := err.(*timeoutError); !ok {
+		t.Fatalf("unexpected error: %+#v", err)
+	}
+
+	if elapsed != time.Minute {
+		t.Fatalf("unexpected elapsed time: %v", elapsed)
+	}
+}
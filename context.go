@@ -0,0 +1,47 @@
+package png
+
+import (
+	"context"
+	"io"
+	"net"
+)
+
+// dialContext dials addr and sets the deadline of ctx to the connection,
+// so that blocking reads and writes are also bounded by ctx.
+func dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if t, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(t)
+	}
+	return conn, nil
+}
+
+// closeOnDone closes c when ctx is done, to interrupt a blocking operation
+// which does not take a context.
+//
+// The returned function must be called after the operation. It waits for the
+// watching goroutine to exit, so that nothing outlives the caller.
+func closeOnDone(ctx context.Context, c io.Closer) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+
+	go func() {
+		defer close(exited)
+
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+	}
+}
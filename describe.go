@@ -0,0 +1,34 @@
+package png
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Describe returns the display form of p, in which passwords are masked and
+// default ports are filled in.
+func Describe(p Pinger) string {
+	if s, ok := p.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T", p)
+}
+
+// displayURL returns u with the password masked and the default port filled in.
+func displayURL(u *url.URL, password secret, port string) string {
+	v := *u
+
+	if v.Port() == "" && port != "" && v.Host != "" {
+		v.Host += ":" + port
+	}
+
+	if v.User != nil {
+		if _, ok := v.User.Password(); ok || password.isSet() {
+			v.User = url.UserPassword(v.User.Username(), "xxxxx")
+		}
+	} else if password.isSet() {
+		v.User = url.UserPassword("", "xxxxx")
+	}
+
+	return v.String()
+}
@@ -0,0 +1,177 @@
+package png
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// DNSPinger sends a query to a DNS resolver over UDP, and retries it over TCP
+// when the answer is truncated.
+type DNSPinger struct {
+	addr  string
+	query dnsQuery
+}
+
+func (p *DNSPinger) String() string {
+	u := &url.URL{Scheme: "dns", Host: p.addr, Path: "/" + p.query.name}
+	return p.query.display(u).String()
+}
+
+func (p *DNSPinger) Ping(ctx context.Context) error {
+	m := p.query.msg()
+
+	c := &dns.Client{Net: "udp"}
+	r, rtt, err := c.ExchangeContext(ctx, m, p.addr)
+	if err == nil && r.Truncated {
+		c.Net = "tcp"
+		r, rtt, err = c.ExchangeContext(ctx, m, p.addr)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return errors.Wrapf(err, "failed in DNS query to %s", p.addr)
+	}
+
+	report(ctx, "dns_protocol", c.Net)
+	report(ctx, "dns_rtt", rtt.String())
+
+	return p.query.check(ctx, r)
+}
+
+// dnsQuery is a query to DNS resolvers, and the assertions on its answer.
+// They are given by the query name and query parameters.
+type dnsQuery struct {
+	name  string
+	qtype uint16
+
+	// rcode is the expected response code, or -1 for any code.
+	rcode int
+	// minAnswers is the minimum number of the records of qtype.
+	minAnswers int
+	// expect are values which must be in the records of qtype.
+	expect []string
+}
+
+// parseDNSQuery moves the query parameters of u into dnsQuery of name.
+func parseDNSQuery(u *url.URL, name string) (dnsQuery, error) {
+	c := dnsQuery{name: strings.TrimSuffix(name, "."), qtype: dns.TypeA, rcode: dns.RcodeSuccess}
+	if c.name == "" {
+		return c, errors.Errorf("no query name in %s URL", u.Scheme)
+	}
+	if _, ok := dns.IsDomainName(c.name); !ok {
+		return c, errors.Errorf("invalid query name: %#v", c.name)
+	}
+
+	q := u.Query()
+	defer func() {
+		u.RawQuery = q.Encode()
+	}()
+
+	if v, ok := q["type"]; ok {
+		qtype, ok := dns.StringToType[strings.ToUpper(v[0])]
+		if !ok {
+			return c, errors.Errorf("invalid type: %#v", v[0])
+		}
+		c.qtype = qtype
+		q.Del("type")
+	}
+
+	if v, ok := q["rcode"]; ok {
+		if strings.EqualFold(v[0], "any") {
+			c.rcode = -1
+		} else if rcode, ok := dns.StringToRcode[strings.ToUpper(v[0])]; ok {
+			c.rcode = rcode
+		} else {
+			return c, errors.Errorf("invalid rcode: %#v", v[0])
+		}
+		q.Del("rcode")
+	}
+
+	if v, ok := q["min_answers"]; ok {
+		n, err := strconv.Atoi(v[0])
+		if err != nil || n < 0 {
+			return c, errors.Errorf("invalid min_answers: %#v", v[0])
+		}
+		c.minAnswers = n
+		q.Del("min_answers")
+	}
+
+	if v, ok := q["expect"]; ok {
+		c.expect = v
+		q.Del("expect")
+	}
+
+	return c, nil
+}
+
+// display returns u with the query parameters of c.
+func (c dnsQuery) display(u *url.URL) *url.URL {
+	v := *u
+	q := v.Query()
+	q.Set("type", dns.TypeToString[c.qtype])
+	switch c.rcode {
+	case dns.RcodeSuccess:
+	case -1:
+		q.Set("rcode", "any")
+	default:
+		q.Set("rcode", dns.RcodeToString[c.rcode])
+	}
+	if c.minAnswers > 0 {
+		q.Set("min_answers", strconv.Itoa(c.minAnswers))
+	}
+	for _, value := range c.expect {
+		q.Add("expect", value)
+	}
+	v.RawQuery = q.Encode()
+	return &v
+}
+
+// msg returns a recursive query message of c.
+func (c dnsQuery) msg() *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(c.name), c.qtype)
+	return m
+}
+
+// check reports the answer r, and checks it.
+func (c dnsQuery) check(ctx context.Context, r *dns.Msg) error {
+	var values []string
+	for _, rr := range r.Answer {
+		if rr.Header().Rrtype == c.qtype {
+			values = append(values, strings.TrimPrefix(rr.String(), rr.Header().String()))
+		}
+	}
+
+	rcode := dns.RcodeToString[r.Rcode]
+	report(ctx, "dns_rcode", rcode)
+	report(ctx, "dns_answers", strings.Join(values, ", "))
+
+	if c.rcode >= 0 && r.Rcode != c.rcode {
+		return errors.Errorf("rcode is %s (expected %s)", rcode, dns.RcodeToString[c.rcode])
+	}
+
+	if len(values) < c.minAnswers {
+		return errors.Errorf("%d %s records answered (less than %d)", len(values), dns.TypeToString[c.qtype], c.minAnswers)
+	}
+
+	for _, expect := range c.expect {
+		found := false
+		for _, value := range values {
+			if strings.EqualFold(strings.TrimSuffix(value, "."), strings.TrimSuffix(expect, ".")) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("%s record %#v not answered", dns.TypeToString[c.qtype], expect)
+		}
+	}
+
+	return nil
+}
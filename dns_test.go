@@ -0,0 +1,163 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/png/pngtest"
+	"github.com/miekg/dns"
+)
+
+func TestDNSPingerPing(t *testing.T) {
+	ping := func(t *testing.T, rawurl string) (map[string]string, error) {
+		p, err := Parse(rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		ctx, r := WithReport(ctx)
+
+		err = p.Ping(ctx)
+
+		details := make(map[string]string)
+		for _, d := range r.Details() {
+			details[d.Name] = d.Value
+		}
+		return details, err
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		s := pngtest.NewDNSServer()
+		defer s.Close()
+
+		details, err := ping(t, s.URL+"&expect=192.0.2.1&min_answers=1")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		if details["dns_protocol"] != "udp" || details["dns_rcode"] != "NOERROR" || details["dns_answers"] != "192.0.2.1" || details["dns_rtt"] == "" {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+	})
+
+	t.Run("Truncated", func(t *testing.T) {
+		s := pngtest.NewDNSServer()
+		defer s.Close()
+		s.SetTruncate(true)
+
+		details, err := ping(t, s.URL)
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		if details["dns_protocol"] != "tcp" || details["dns_answers"] != "192.0.2.1" {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+	})
+
+	for _, c := range []struct {
+		name   string
+		setup  func(s *pngtest.DNSServer)
+		params string
+		msg    string
+	}{
+		{"Server Failure", func(s *pngtest.DNSServer) {
+			s.SetRcode(dns.RcodeServerFailure)
+		}, "", "rcode is SERVFAIL (expected NOERROR)"},
+		{"Unexpected Rcode", func(s *pngtest.DNSServer) {}, "&rcode=nxdomain", "rcode is NOERROR (expected NXDOMAIN)"},
+		{"Few Answers", func(s *pngtest.DNSServer) {}, "&min_answers=2", "1 A records answered (less than 2)"},
+		{"Unexpected Value", func(s *pngtest.DNSServer) {}, "&expect=192.0.2.2", "A record \"192.0.2.2\" not answered"},
+		{"Timeout", func(s *pngtest.DNSServer) {
+			s.SetLatency(2 * time.Second)
+		}, "", "failed in DNS query to 127.0.0.1:"},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			s := pngtest.NewDNSServer()
+			defer s.Close()
+			c.setup(s)
+
+			_, err := ping(t, s.URL+c.params)
+			if err == nil {
+				t.Fatal("succeeded to ping")
+			}
+
+			if msg := err.Error(); !strings.HasPrefix(msg, c.msg) {
+				t.Fatalf("unexpected error message: %#v", msg)
+			}
+		})
+	}
+
+	t.Run("Any Rcode", func(t *testing.T) {
+		s := pngtest.NewDNSServer()
+		defer s.Close()
+		s.SetRcode(dns.RcodeNameError)
+
+		details, err := ping(t, s.URL+"&rcode=any")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		if details["dns_rcode"] != "NXDOMAIN" {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+	})
+
+	t.Run("MX", func(t *testing.T) {
+		s := pngtest.NewDNSServer()
+		defer s.Close()
+		s.SetRecords("example.com. 300 IN MX 10 mail.example.com.", "example.com. 300 IN MX 20 mail2.example.com.")
+
+		details, err := ping(t, "dns://"+s.Addr+"/example.com?type=mx&expect=10+mail.example.com&min_answers=2")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		if details["dns_answers"] != "10 mail.example.com., 20 mail2.example.com." {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+	})
+}
+
+func TestParseDNSURL(t *testing.T) {
+	for _, c := range []struct {
+		rawurl, display string
+	}{
+		{"dns://resolver/example.com", "dns://resolver:53/example.com?type=A"},
+		{"dns://resolver:5353/example.com.?type=aaaa", "dns://resolver:5353/example.com?type=AAAA"},
+		{"dns://[::1]/example.com?rcode=NXDOMAIN", "dns://[::1]:53/example.com?rcode=NXDOMAIN&type=A"},
+		{"dns://resolver/example.com?type=TXT&min_answers=1&expect=a&expect=b", "dns://resolver:53/example.com?expect=a&expect=b&min_answers=1&type=TXT"},
+	} {
+		p, err := Parse(c.rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(%#v): %+#v", c.rawurl, err)
+		}
+
+		if s := Describe(p); s != c.display {
+			t.Fatalf("unexpected display form of %#v: %#v", c.rawurl, s)
+		}
+	}
+
+	for _, c := range []struct {
+		rawurl, msg string
+	}{
+		{"dns://resolver", "no query name in dns URL"},
+		{"dns://resolver/example.com?type=FOO", "invalid type: \"FOO\""},
+		{"dns://resolver/example.com?rcode=FOO", "invalid rcode: \"FOO\""},
+		{"dns://resolver/example.com?min_answers=-1", "invalid min_answers: \"-1\""},
+	} {
+		p, err := Parse(c.rawurl)
+		if err == nil {
+			t.Fatalf("succeeded in Parse(%#v): %#v", c.rawurl, p)
+		}
+
+		if msg := err.Error(); msg != c.msg {
+			t.Fatalf("unexpected error message of %#v: %#v", c.rawurl, msg)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package png
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+)
+
+// DoTPinger sends a query to a DNS over TLS resolver (RFC 7858).
+type DoTPinger struct {
+	addr  string
+	tls   tlsOptions
+	query dnsQuery
+}
+
+func (p *DoTPinger) String() string {
+	u := &url.URL{Scheme: "dot", Host: p.addr, Path: "/" + p.query.name}
+	return p.query.display(u).String()
+}
+
+func (p *DoTPinger) Ping(ctx context.Context) error {
+	o := p.tls
+	o.set = true
+
+	start := time.Now()
+	conn, err := o.dial(ctx, "tcp", p.addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed in connecting %s", p.addr)
+	}
+	defer conn.Close()
+	report(ctx, "dns_handshake", time.Since(start).String())
+
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	m := p.query.msg()
+	r, rtt, err := exchangeConn(&dns.Conn{Conn: conn}, m)
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return errors.Wrapf(err, "failed in DNS query to %s", p.addr)
+	}
+	report(ctx, "dns_protocol", "tls")
+	report(ctx, "dns_rtt", rtt.String())
+
+	return p.query.check(ctx, r)
+}
+
+// exchangeConn sends m on conn, and reads the answer to m.
+func exchangeConn(conn *dns.Conn, m *dns.Msg) (*dns.Msg, time.Duration, error) {
+	start := time.Now()
+	if err := conn.WriteMsg(m); err != nil {
+		return nil, 0, err
+	}
+
+	r, err := conn.ReadMsg()
+	if err != nil {
+		return nil, 0, err
+	}
+	if r.Id != m.Id {
+		return nil, 0, dns.ErrId
+	}
+	return r, time.Since(start), nil
+}
+
+// DoHPinger sends a query to a DNS over HTTPS resolver by POST of the wire
+// format (RFC 8484).
+type DoHPinger struct {
+	addr  string
+	path  string
+	tls   tlsOptions
+	query dnsQuery
+}
+
+func (p *DoHPinger) String() string {
+	u := &url.URL{Scheme: "doh", Host: p.addr, Path: "/" + p.query.name}
+	u = p.query.display(u)
+	if p.path != "/dns-query" {
+		q := u.Query()
+		q.Set("path", p.path)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+func (p *DoHPinger) Ping(ctx context.Context) error {
+	fail := func(err error) error {
+		return errors.Wrapf(err, "failed in DNS query to %s", p.addr)
+	}
+
+	m := p.query.msg()
+	// The ID is 0 to be friendly to HTTP caches.
+	m.Id = 0
+	data, err := m.Pack()
+	if err != nil {
+		return fail(err)
+	}
+
+	tlsConfig, err := p.tls.config(ctx, "")
+	if err != nil {
+		return fail(err)
+	}
+
+	// The transport lives only for this query, and has no proxy, so that
+	// dns_handshake is of the resolver itself.
+	transport := &http.Transport{
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: true,
+		ForceAttemptHTTP2: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	// The handshake is from connecting to the end of the TLS handshake, and
+	// the query is after it.
+	var connected, handshaked time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(network, addr string) {
+			if connected.IsZero() {
+				connected = time.Now()
+			}
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			handshaked = time.Now()
+		},
+	}
+
+	u := &url.URL{Scheme: "https", Host: p.addr, Path: p.path}
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(data))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	req.Header.Set("User-Agent", "png/0.0.0-dev")
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	resp, err := (&http.Client{Transport: transport}).Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed in DNS query to %s by HTTP %s", p.addr, resp.Status)
+	}
+	if typ, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); typ != "application/dns-message" {
+		return errors.Errorf("failed in DNS query to %s by Content-Type %#v", p.addr, resp.Header.Get("Content-Type"))
+	}
+
+	data, err = ioutil.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	if err != nil {
+		return fail(err)
+	}
+	r := new(dns.Msg)
+	if err := r.Unpack(data); err != nil {
+		return fail(err)
+	}
+	if r.Id != m.Id {
+		return fail(dns.ErrId)
+	}
+
+	report(ctx, "dns_protocol", resp.Proto)
+	if !handshaked.IsZero() {
+		report(ctx, "dns_handshake", handshaked.Sub(connected).String())
+		report(ctx, "dns_rtt", time.Since(handshaked).String())
+	}
+
+	return p.query.check(ctx, r)
+}
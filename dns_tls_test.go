@@ -0,0 +1,190 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/png/pngtest"
+	"github.com/miekg/dns"
+)
+
+func TestDNSOverTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "png")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := pngtest.NewCA()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, ca.CertPEM, 0600); err != nil {
+		panic(err)
+	}
+
+	s := pngtest.NewDNSServer()
+	defer s.Close()
+	s.StartTLS(ca.ServerConfig(ca.Issue("127.0.0.1"), false))
+
+	ping := func(t *testing.T, rawurl string) (map[string]string, error) {
+		p, err := Parse(rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		ctx, r := WithReport(ctx)
+
+		err = p.Ping(ctx)
+
+		details := make(map[string]string)
+		for _, d := range r.Details() {
+			details[d.Name] = d.Value
+		}
+		return details, err
+	}
+
+	for _, c := range []struct {
+		name, rawurl, protocol string
+	}{
+		{"DoT", s.DoTURL, "tls"},
+		{"DoH", s.DoHURL, "HTTP/2.0"},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Run("OK", func(t *testing.T) {
+				details, err := ping(t, c.rawurl+"&expect=192.0.2.1&tls_report=true&tls_ca="+url.QueryEscape(caFile))
+				if err != nil {
+					t.Fatalf("failed in p.Ping(): %+#v", err)
+				}
+
+				if details["dns_rcode"] != "NOERROR" || details["dns_answers"] != "192.0.2.1" || details["dns_protocol"] != c.protocol {
+					t.Fatalf("unexpected details: %+#v", details)
+				}
+				if details["dns_handshake"] == "" || details["dns_rtt"] == "" || details["tls_version"] == "" {
+					t.Fatalf("unexpected details: %+#v", details)
+				}
+			})
+
+			t.Run("Unexpected Value", func(t *testing.T) {
+				_, err := ping(t, c.rawurl+"&expect=192.0.2.2&tls_ca="+url.QueryEscape(caFile))
+				if err == nil {
+					t.Fatal("succeeded to ping")
+				}
+
+				if msg := err.Error(); msg != "A record \"192.0.2.2\" not answered" {
+					t.Fatalf("unexpected error message: %#v", msg)
+				}
+			})
+
+			t.Run("Unknown CA", func(t *testing.T) {
+				_, err := ping(t, c.rawurl)
+				if err == nil {
+					t.Fatal("succeeded to ping")
+				}
+
+				if msg := err.Error(); !strings.Contains(msg, "certificate signed by unknown authority") {
+					t.Fatalf("unexpected error message: %#v", msg)
+				}
+			})
+		})
+	}
+
+	t.Run("DoH Not Found", func(t *testing.T) {
+		_, err := ping(t, s.DoHURL+"&path=%2Fresolve&tls_ca="+url.QueryEscape(caFile))
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); !strings.HasSuffix(msg, "by HTTP 404 Not Found") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("DoH Invalid Response", func(t *testing.T) {
+		reply := new(dns.Msg)
+		reply.SetQuestion(dns.Fqdn(pngtest.DNSName), dns.TypeA)
+		reply.Response = true
+		reply.Id = 1
+		data, err := reply.Pack()
+		if err != nil {
+			t.Fatalf("failed in reply.Pack(): %+#v", err)
+		}
+
+		for _, c := range []struct {
+			name, contentType, msg string
+		}{
+			{"Content-Type", "text/plain", "by Content-Type \"text/plain\""},
+			{"ID", "application/dns-message", ": dns: id mismatch"},
+		} {
+			c := c
+			t.Run(c.name, func(t *testing.T) {
+				l, err := tls.Listen("tcp", "127.0.0.1:0", ca.ServerConfig(ca.Issue("127.0.0.1"), false))
+				if err != nil {
+					t.Fatalf("failed in tls.Listen(): %+#v", err)
+				}
+				hs := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", c.contentType)
+					w.Write(data)
+				})}
+				go hs.Serve(l)
+				defer hs.Close()
+
+				_, err = ping(t, "doh://"+l.Addr().String()+"/"+pngtest.DNSName+"?tls_ca="+url.QueryEscape(caFile))
+				if err == nil {
+					t.Fatal("succeeded to ping")
+				}
+
+				if msg := err.Error(); !strings.HasSuffix(msg, c.msg) {
+					t.Fatalf("unexpected error message: %#v", msg)
+				}
+			})
+		}
+	})
+}
+
+func TestParseDNSOverTLSURL(t *testing.T) {
+	for _, c := range []struct {
+		rawurl, display string
+	}{
+		{"dot://resolver/example.com", "dot://resolver:853/example.com?type=A"},
+		{"dot://resolver:8853/example.com?type=AAAA&tls_insecure=true", "dot://resolver:8853/example.com?type=AAAA"},
+		{"doh://resolver/example.com", "doh://resolver:443/example.com?type=A"},
+		{"doh://resolver:8443/example.com?path=/resolve&rcode=any", "doh://resolver:8443/example.com?path=%2Fresolve&rcode=any&type=A"},
+	} {
+		p, err := Parse(c.rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(%#v): %+#v", c.rawurl, err)
+		}
+
+		if s := Describe(p); s != c.display {
+			t.Fatalf("unexpected display form of %#v: %#v", c.rawurl, s)
+		}
+	}
+
+	for _, c := range []struct {
+		rawurl, msg string
+	}{
+		{"dot://resolver", "no query name in dot URL"},
+		{"doh://resolver/", "no query name in doh URL"},
+		{"doh://resolver/example.com?path=resolve", "invalid path: \"resolve\" (must begin with /)"},
+	} {
+		p, err := Parse(c.rawurl)
+		if err == nil {
+			t.Fatalf("succeeded in Parse(%#v): %#v", c.rawurl, p)
+		}
+
+		if msg := err.Error(); msg != c.msg {
+			t.Fatalf("unexpected error message of %#v: %#v", c.rawurl, msg)
+		}
+	}
+}
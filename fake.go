@@ -0,0 +1,84 @@
+package png
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FakeResult is an outcome of FakePinger.Ping.
+type FakeResult struct {
+	// Delay is how long Ping takes.
+	Delay time.Duration
+	// Err is returned after Delay.
+	Err error
+	// Timeout makes Ping wait until the context is done.
+	Timeout bool
+}
+
+// FakeOK returns a successful result after delay.
+func FakeOK(delay time.Duration) FakeResult {
+	return FakeResult{Delay: delay}
+}
+
+// FakeError returns a result of err after delay.
+func FakeError(delay time.Duration, err error) FakeResult {
+	return FakeResult{Delay: delay, Err: err}
+}
+
+// FakeTimeout returns a result never finishing before the context is done.
+func FakeTimeout() FakeResult {
+	return FakeResult{Timeout: true}
+}
+
+// FakePinger is a Pinger which plays back results in order, for testing
+// code built on Pinger. It starts over from the first result after the last
+// one.
+type FakePinger struct {
+	// Clock is used to wait delays. SystemClock is used when it is nil.
+	Clock   Clock
+	Results []FakeResult
+
+	mu    sync.Mutex
+	count int
+}
+
+func NewFakePinger(clock Clock, results ...FakeResult) *FakePinger {
+	return &FakePinger{Clock: clock, Results: results}
+}
+
+// Count returns the number of pings so far.
+func (p *FakePinger) Count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.count
+}
+
+func (p *FakePinger) Ping(ctx context.Context) error {
+	p.mu.Lock()
+	var r FakeResult
+	if len(p.Results) > 0 {
+		r = p.Results[p.count%len(p.Results)]
+	}
+	p.count++
+	p.mu.Unlock()
+
+	clock := p.Clock
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	if r.Timeout {
+		if deadline, ok := ctx.Deadline(); ok {
+			clock.Sleep(ctx, deadline.Sub(clock.Now()))
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	if err := clock.Sleep(ctx, r.Delay); err != nil {
+		return err
+	}
+	return r.Err
+}
@@ -0,0 +1,68 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestFakePinger(t *testing.T) {
+	t.Run("Results", func(t *testing.T) {
+		clock := pngtest.NewFakeClock()
+		refused := errors.New("connection refused")
+		p := NewFakePinger(clock, FakeOK(10*time.Millisecond), FakeError(5*time.Millisecond, refused))
+
+		for i, want := range []struct {
+			err     error
+			elapsed time.Duration
+		}{
+			{nil, 10 * time.Millisecond},
+			{refused, 5 * time.Millisecond},
+			{nil, 10 * time.Millisecond},
+		} {
+			start := clock.Now()
+			err := p.Ping(context.Background())
+			elapsed := clock.Now().Sub(start)
+
+			if err != want.err {
+				t.Fatalf("unexpected error at %d: %+#v", i, err)
+			}
+			if elapsed != want.elapsed {
+				t.Fatalf("unexpected elapsed time at %d: %v", i, elapsed)
+			}
+		}
+
+		if n := p.Count(); n != 3 {
+			t.Fatalf("unexpected count: %d", n)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		p := NewFakePinger(pngtest.NewFakeClock(), FakeTimeout())
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := p.Ping(ctx); err != context.Canceled {
+			t.Fatalf("unexpected error: %+#v", err)
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		clock := pngtest.NewFakeClock()
+		p := NewFakePinger(clock, FakeOK(10*time.Millisecond))
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		start := clock.Now()
+		if err := p.Ping(ctx); err != context.Canceled {
+			t.Fatalf("unexpected error: %+#v", err)
+		}
+		if elapsed := clock.Now().Sub(start); elapsed != 0 {
+			t.Fatalf("unexpected elapsed time: %v", elapsed)
+		}
+	})
+}
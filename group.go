@@ -0,0 +1,144 @@
+package png
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GroupPinger pings its children concurrently, and succeeds when at least
+// the required number of them succeed.
+type GroupPinger struct {
+	mode     string
+	required int
+	pingers  []Pinger
+}
+
+// All returns a Pinger succeeding when all of pingers succeed. It panics
+// without pingers, like the parser rejecting a group without targets.
+func All(pingers ...Pinger) *GroupPinger {
+	return newGroupPinger("all", len(pingers), pingers)
+}
+
+// Any returns a Pinger succeeding when any of pingers succeeds. It panics
+// without pingers.
+func Any(pingers ...Pinger) *GroupPinger {
+	return newGroupPinger("any", 1, pingers)
+}
+
+// Quorum returns a Pinger succeeding when at least n of pingers succeed. It
+// panics unless n is in 1 to len(pingers), like a group never succeeding.
+func Quorum(n int, pingers ...Pinger) *GroupPinger {
+	if len(pingers) > 0 && (n < 1 || n > len(pingers)) {
+		panic(fmt.Sprintf("png: invalid quorum: %d (must be in 1 to %d)", n, len(pingers)))
+	}
+	return newGroupPinger("quorum", n, pingers)
+}
+
+func newGroupPinger(mode string, required int, pingers []Pinger) *GroupPinger {
+	if len(pingers) == 0 {
+		panic(fmt.Sprintf("png: no pingers in %s group", mode))
+	}
+	return &GroupPinger{mode: mode, required: required, pingers: pingers}
+}
+
+// GroupError is the error of GroupPinger, when not enough children succeed.
+type GroupError struct {
+	Mode string
+	// Succeeded is the number of the children succeeded before the result is
+	// decided. Children still running then are cancelled and not counted, so
+	// it depends on timing when the result is decided by failures.
+	Succeeded int
+	Required  int
+	// Failures are the failed children. Children cancelled after the result
+	// is decided are not contained.
+	Failures []*GroupFailure
+}
+
+// GroupFailure is a failed child of GroupPinger.
+type GroupFailure struct {
+	// Name is the display form of the child, or "#N" (1-based index) if it
+	// has no display form.
+	Name string
+	Err  error
+}
+
+func (e *GroupError) Error() string {
+	failures := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failures[i] = fmt.Sprintf("%s: %v", f.Name, f.Err)
+	}
+
+	return fmt.Sprintf("failed in %s ping (%d succeeded, %d required): %s",
+		e.Mode, e.Succeeded, e.Required, strings.Join(failures, "; "))
+}
+
+func (p *GroupPinger) String() string {
+	q := url.Values{}
+	if p.mode == "quorum" {
+		q.Set("n", strconv.Itoa(p.required))
+	}
+	for _, pinger := range p.pingers {
+		q.Add("target", Describe(pinger))
+	}
+	return p.mode + "://?" + q.Encode()
+}
+
+func (p *GroupPinger) Ping(ctx context.Context) error {
+	type result struct {
+		i   int
+		err error
+	}
+
+	// Cancels the remaining children once the result is decided.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan result, len(p.pingers))
+	for i, pinger := range p.pingers {
+		go func(i int, pinger Pinger) {
+			results <- result{i: i, err: pinger.Ping(ctx)}
+		}(i, pinger)
+	}
+
+	// The result is decided when `required` children succeed, or when more
+	// than `len(p.pingers) - required` children fail.
+	errs := make([]error, len(p.pingers))
+	succeeded, failed := 0, 0
+	remaining := len(p.pingers)
+	for ; remaining > 0 && succeeded < p.required && failed <= len(p.pingers)-p.required; remaining-- {
+		r := <-results
+		if r.err == nil {
+			succeeded++
+		} else {
+			errs[r.i] = r.err
+			failed++
+		}
+	}
+
+	cancel()
+	for ; remaining > 0; remaining-- {
+		<-results
+	}
+
+	if succeeded >= p.required {
+		return nil
+	}
+
+	e := &GroupError{Mode: p.mode, Succeeded: succeeded, Required: p.required}
+	for i, err := range errs {
+		if err != nil {
+			e.Failures = append(e.Failures, &GroupFailure{Name: describeChild(i, p.pingers[i]), Err: err})
+		}
+	}
+	return e
+}
+
+func describeChild(i int, p Pinger) string {
+	if s, ok := p.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
@@ -0,0 +1,120 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func TestGroupPinger(t *testing.T) {
+	refused := errors.New("connection refused")
+	ok := NewFakePinger(nil, FakeOK(0))
+	fail := NewFakePinger(nil, FakeError(0, refused))
+	hang := NewFakePinger(nil, FakeTimeout())
+
+	t.Run("All", func(t *testing.T) {
+		if err := All(ok, ok).Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		// The number of the succeeded children depends on timing, since it
+		// stops at the first failure.
+		err := All(ok, fail, ok).Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded in p.Ping()")
+		}
+
+		ge, ok := err.(*GroupError)
+		if !ok {
+			t.Fatalf("failed in casting to *GroupError: %+#v", err)
+		}
+
+		if ge.Required != 3 || len(ge.Failures) != 1 || ge.Failures[0].Name != "#2" || ge.Failures[0].Err != refused {
+			t.Fatalf("unexpected error: %+#v", ge)
+		}
+	})
+
+	t.Run("Any", func(t *testing.T) {
+		if err := Any(fail, ok).Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		err := Any(fail, fail).Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded in p.Ping()")
+		}
+
+		ge, ok := err.(*GroupError)
+		if !ok {
+			t.Fatalf("failed in casting to *GroupError: %+#v", err)
+		}
+
+		if len(ge.Failures) != 2 || ge.Failures[0].Name != "#1" || ge.Failures[1].Err != refused {
+			t.Fatalf("unexpected failures: %+#v", ge.Failures)
+		}
+	})
+
+	t.Run("Quorum", func(t *testing.T) {
+		// It does not wait for the hanging child once 2 children succeed.
+		if err := Quorum(2, ok, hang, ok).Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		// It does not wait for the hanging child once 2 children fail.
+		err := Quorum(2, fail, hang, fail).Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded in p.Ping()")
+		}
+
+		if msg := err.Error(); msg != "failed in quorum ping (0 succeeded, 2 required): #1: connection refused; #3: connection refused" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Invalid Quorum", func(t *testing.T) {
+		for _, n := range []int{0, 3} {
+			func() {
+				defer func() {
+					if r := recover(); r != fmt.Sprintf("png: invalid quorum: %d (must be in 1 to 2)", n) {
+						t.Fatalf("unexpected panic: %#v", r)
+					}
+				}()
+				Quorum(n, ok, ok)
+			}()
+		}
+	})
+
+	t.Run("No Pingers", func(t *testing.T) {
+		for mode, group := range map[string]func(){
+			"all":    func() { All() },
+			"any":    func() { Any() },
+			"quorum": func() { Quorum(1) },
+		} {
+			func() {
+				defer func() {
+					if r := recover(); r != "png: no pingers in "+mode+" group" {
+						t.Fatalf("unexpected panic: %#v", r)
+					}
+				}()
+				group()
+			}()
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := All(ok, hang).Ping(ctx)
+		if err == nil {
+			t.Fatal("succeeded in p.Ping()")
+		}
+
+		if msg := err.Error(); msg != "failed in all ping (1 succeeded, 2 required): #2: context deadline exceeded" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
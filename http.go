@@ -10,6 +10,15 @@ import (
 
 type HTTPPinger struct {
 	url *url.URL
+	tls tlsOptions
+}
+
+func (p *HTTPPinger) String() string {
+	port := "80"
+	if p.url.Scheme == "https" {
+		port = "443"
+	}
+	return displayURL(p.url, secret{}, port)
 }
 
 func (p *HTTPPinger) Ping(ctx context.Context) error {
@@ -21,7 +30,21 @@ func (p *HTTPPinger) Ping(ctx context.Context) error {
 	req.Header.Add("User-Agent", "png/0.0.0-dev")
 	req = req.WithContext(ctx)
 
-	resp, err := http.DefaultClient.Do(req)
+	tlsConfig, err := p.tls.config(ctx, "")
+	if err != nil {
+		return errors.Wrap(err, "failed in HTTP request")
+	}
+
+	// A transport per ping, not to keep idle connections after the ping.
+	transport := &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: true,
+	}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{Transport: transport}
+	resp, err := client.Do(req)
 	if err != nil {
 		return errors.Wrap(err, "failed in HTTP request")
 	}
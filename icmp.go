@@ -0,0 +1,176 @@
+package png
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// ICMPPinger sends an ICMP echo request, and waits for the echo reply.
+//
+// It uses an unprivileged ICMP datagram socket, which Linux allows by
+// net.ipv4.ping_group_range, and falls back to a raw socket.
+type ICMPPinger struct {
+	// scheme is "icmp" or "icmp6".
+	scheme string
+	host   string
+
+	seq uint32
+}
+
+func (p *ICMPPinger) String() string {
+	return (&url.URL{Scheme: p.scheme, Host: p.host}).String()
+}
+
+// icmpNetworks are the networks of ICMP sockets by scheme, unprivileged one
+// first.
+var icmpNetworks = map[string][2]string{
+	"icmp":  {"udp4", "ip4:icmp"},
+	"icmp6": {"udp6", "ip6:ipv6-icmp"},
+}
+
+func (p *ICMPPinger) Ping(ctx context.Context) error {
+	ip, err := p.resolve(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed in resolving host")
+	}
+
+	conn, network, err := p.listen()
+	if err != nil {
+		return errors.Wrap(err, "failed in opening ICMP socket")
+	}
+	defer conn.Close()
+
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	if t, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(t)
+	}
+
+	var typ, replyType icmp.Type = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if p.scheme == "icmp6" {
+		typ, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+		conn.IPv6PacketConn().SetControlMessage(ipv6.FlagHopLimit, true)
+	} else {
+		conn.IPv4PacketConn().SetControlMessage(ipv4.FlagTTL, true)
+	}
+	if network[:3] == "udp" {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	// The ID is replaced with the local port by the kernel on a datagram
+	// socket, so that only the sequence number is checked there.
+	id := os.Getpid() & 0xffff
+	seq := int(atomic.AddUint32(&p.seq, 1) & 0xffff)
+	msg := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: []byte("png")},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return errors.Wrap(err, "failed in ICMP echo")
+	}
+
+	start := time.Now()
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return p.fail(ctx, err)
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, ttl, from, err := p.read(conn, buf)
+		if err != nil {
+			return p.fail(ctx, err)
+		}
+		rtt := time.Since(start)
+
+		reply, err := icmp.ParseMessage(typ.Protocol(), buf[:n])
+		if err != nil || reply.Type != replyType {
+			continue
+		}
+		echo, ok := reply.Body.(*icmp.Echo)
+		if !ok || echo.Seq != seq || (network[:3] != "udp" && echo.ID != id) {
+			continue
+		}
+
+		report(ctx, "icmp_addr", from.String())
+		report(ctx, "icmp_seq", strconv.Itoa(seq))
+		report(ctx, "icmp_rtt", rtt.String())
+		if ttl >= 0 {
+			report(ctx, "icmp_ttl", strconv.Itoa(ttl))
+		}
+		return nil
+	}
+}
+
+// resolve returns an IP address of p.host in the IP version of p.scheme.
+func (p *ICMPPinger) resolve(ctx context.Context) (net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, p.host)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, addr := range addrs {
+		if (addr.IP.To4() != nil) == (p.scheme == "icmp") {
+			return addr.IP, nil
+		}
+	}
+
+	version := "IPv4"
+	if p.scheme == "icmp6" {
+		version = "IPv6"
+	}
+	return nil, errors.Errorf("no %s address of %s", version, p.host)
+}
+
+// listen opens an unprivileged ICMP socket, or a raw socket if it is not
+// allowed.
+func (p *ICMPPinger) listen() (*icmp.PacketConn, string, error) {
+	networks := icmpNetworks[p.scheme]
+
+	conn, err := icmp.ListenPacket(networks[0], "")
+	if err == nil {
+		return conn, networks[0], nil
+	}
+
+	conn, rawErr := icmp.ListenPacket(networks[1], "")
+	if rawErr != nil {
+		return nil, "", errors.Errorf("%v (raw socket: %v)", err, rawErr)
+	}
+	return conn, networks[1], nil
+}
+
+// read reads a packet with its TTL or hop limit, which is -1 when unknown.
+func (p *ICMPPinger) read(conn *icmp.PacketConn, b []byte) (int, int, net.Addr, error) {
+	if p.scheme == "icmp6" {
+		n, cm, from, err := conn.IPv6PacketConn().ReadFrom(b)
+		if cm == nil {
+			return n, -1, from, err
+		}
+		return n, cm.HopLimit, from, err
+	}
+
+	n, cm, from, err := conn.IPv4PacketConn().ReadFrom(b)
+	if cm == nil {
+		return n, -1, from, err
+	}
+	return n, cm.TTL, from, err
+}
+
+func (p *ICMPPinger) fail(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "failed in ICMP echo")
+	}
+	return errors.Wrap(err, "failed in ICMP echo")
+}
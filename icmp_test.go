@@ -0,0 +1,82 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"strings"
+	"time"
+)
+
+func TestICMPPinger(t *testing.T) {
+	for _, c := range []struct {
+		name, rawurl string
+	}{
+		{"ICMP", "icmp://127.0.0.1"},
+		{"ICMP6", "icmp6://[::1]"},
+	} {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			p, err := Parse(c.rawurl)
+			if err != nil {
+				t.Fatalf("failed in Parse(): %+#v", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			ctx, r := WithReport(ctx)
+
+			err = p.Ping(ctx)
+			if err != nil && strings.HasPrefix(err.Error(), "failed in opening ICMP socket: ") {
+				t.Skipf("ICMP socket is not available: %v", err)
+			}
+			if err != nil {
+				t.Fatalf("failed in p.Ping(): %+#v", err)
+			}
+
+			details := make(map[string]string)
+			for _, d := range r.Details() {
+				details[d.Name] = d.Value
+			}
+			if details["icmp_seq"] != "1" || details["icmp_rtt"] == "" {
+				t.Fatalf("unexpected details: %+#v", details)
+			}
+
+			if err := p.Ping(context.Background()); err != nil {
+				t.Fatalf("failed in p.Ping(): %+#v", err)
+			}
+		})
+	}
+
+	t.Run("No IPv6 Address", func(t *testing.T) {
+		p := &ICMPPinger{scheme: "icmp6", host: "127.0.0.1"}
+		err := p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); msg != "failed in resolving host: no IPv6 address of 127.0.0.1" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
+
+func TestParseICMPURL(t *testing.T) {
+	p, err := Parse("icmp://localhost")
+	if err != nil {
+		t.Fatalf("failed in Parse(): %+#v", err)
+	}
+
+	if s := Describe(p); s != "icmp://localhost" {
+		t.Fatalf("unexpected display form: %#v", s)
+	}
+
+	p, err = Parse("icmp://localhost:80")
+	if err == nil {
+		t.Fatal("succeeded in Parse()", p)
+	}
+
+	if msg := err.Error(); msg != "invalid icmp URL: \"localhost:80\" (no port in ICMP)" {
+		t.Fatalf("unexpected error message: %#v", msg)
+	}
+}
@@ -0,0 +1,112 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"time"
+)
+
+func countFDs() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0
+	}
+	return len(fds)
+}
+
+// checkLeaks fails when f leaves goroutines or file descriptors behind.
+func checkLeaks(t *testing.T, f func()) {
+	goroutines, fds := runtime.NumGoroutine(), countFDs()
+
+	f()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		g, n := runtime.NumGoroutine(), countFDs()
+		if g <= goroutines && n <= fds {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("leaked %d goroutines and %d file descriptors", g-goroutines, n-fds)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPingerLeak(t *testing.T) {
+	n := 1000
+	if testing.Short() {
+		n = 100
+	}
+
+	pingTimeout := func(t *testing.T, p Pinger) {
+		checkLeaks(t, func() {
+			for i := 0; i < n; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				err := p.Ping(ctx)
+				cancel()
+
+				if err == nil {
+					t.Fatal("succeeded in p.Ping()")
+				}
+			}
+		})
+	}
+
+	// A server reading requests until the client goes away, without any response.
+	s, addr := runTCPServer("tcp", "localhost:0", func(conn net.Conn) {
+		ioutil.ReadAll(conn)
+	})
+	defer s.Close()
+
+	hs, u := runHTTPServer(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	defer hs.Close()
+
+	t.Run("HTTP", func(t *testing.T) {
+		pingTimeout(t, &HTTPPinger{url: u})
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		wu := *u
+		wu.Scheme = "ws"
+		pingTimeout(t, &WebSocketPinger{url: &wu})
+	})
+
+	t.Run("Redis", func(t *testing.T) {
+		pingTimeout(t, &RedisPinger{addr: addr})
+	})
+
+	t.Run("AMQP", func(t *testing.T) {
+		pingTimeout(t, &AMQPPinger{url: &url.URL{Scheme: "amqp", Host: addr}})
+	})
+
+	t.Run("MySQL", func(t *testing.T) {
+		pingTimeout(t, &MySQLPinger{url: &url.URL{Scheme: "mysql", Host: addr}})
+	})
+
+	t.Run("PostgreSQL", func(t *testing.T) {
+		pingTimeout(t, &PostgresPinger{url: &url.URL{Scheme: "postgres", Host: addr, RawQuery: "sslmode=disable"}})
+	})
+
+	t.Run("TCP", func(t *testing.T) {
+		// A TCP ping may succeed before the timeout, since the server accepts
+		// connections.
+		p := &TCPPinger{network: "tcp", addr: addr}
+		checkLeaks(t, func() {
+			for i := 0; i < n; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+				p.Ping(ctx)
+				cancel()
+			}
+		})
+	})
+}
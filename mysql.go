@@ -3,27 +3,193 @@ package png
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/pkg/errors"
-
-	_ "github.com/go-sql-driver/mysql"
 )
 
 type MySQLPinger struct {
-	url *url.URL
+	url      *url.URL
+	password secret
+	tls      tlsOptions
+	query    queryCheck
+	checks   mysqlChecks
+}
+
+// mysqlTLSSerial makes names of TLS configs registered to the MySQL driver.
+var mysqlTLSSerial uint64
+
+func (p *MySQLPinger) String() string {
+	return displayURL(p.checks.display(p.query.display(p.url)), p.password, "3306")
 }
 
 func (p *MySQLPinger) Ping(ctx context.Context) error {
-	db, _ := sql.Open("mysql", p.urlToDSN())
-	// sql.Open() must be succeeded when driver name is correct.
+	u, password, err := p.password.userURL(p.url)
+	if err != nil {
+		return errors.Wrap(err, "failed in MySQL ping")
+	}
+
+	if p.tls.set {
+		// The MySQL driver takes a TLS config only by a registered name.
+		config, err := p.tls.config(ctx, u.Host)
+		if err != nil {
+			return errors.Wrap(err, "failed in MySQL ping")
+		}
+
+		name := fmt.Sprintf("png-%d", atomic.AddUint64(&mysqlTLSSerial, 1))
+		mysql.RegisterTLSConfig(name, config)
+		defer mysql.DeregisterTLSConfig(name)
+
+		q := u.Query()
+		q.Set("tls", name)
+		v := *u
+		v.RawQuery = q.Encode()
+		u = &v
+	}
+
+	dsn, err := urlToDSN(u)
+	if err != nil {
+		return errors.Wrap(err, "failed in MySQL ping")
+	}
+
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return errors.Wrap(err, "failed in MySQL ping")
+	}
+	config.Net = mysqlNetworks[config.Net]
+
+	connector, err := mysql.NewConnector(config)
+	if err != nil {
+		return errors.Wrap(err, "failed in MySQL ping")
+	}
+
+	db := sql.OpenDB(mysqlConnector{connector})
 	defer db.Close()
 
-	return errors.Wrap(db.PingContext(ctx), "failed in MySQL ping")
+	if err := db.PingContext(ctx); err != nil {
+		return redact(errors.Wrap(err, "failed in MySQL ping"), password)
+	}
+
+	if err := p.checks.check(ctx, db); err != nil {
+		return redact(errors.Wrap(err, "failed in MySQL replication check"), password)
+	}
+
+	return redact(errors.Wrap(p.query.check(ctx, db), "failed in MySQL query"), password)
+}
+
+// mysqlNetworks are the names of the dialers registered to the MySQL driver
+// by network.
+var mysqlNetworks = map[string]string{
+	"tcp":  "png-tcp",
+	"unix": "png-unix",
+}
+
+func init() {
+	for network, name := range mysqlNetworks {
+		network := network
+		mysql.RegisterDialContext(name, func(ctx context.Context, addr string) (net.Conn, error) {
+			return mysqlDial(ctx, network, addr)
+		})
+	}
 }
 
-func (p *MySQLPinger) urlToDSN() string {
-	u := *p.url
-	u.Host = "tcp(" + u.Host + ")"
-	return u.String()[len("mysql://"):]
+// mysqlConnector passes the context of a ping to mysqlDial. The driver
+// gives dialers a context of its own, which ends when the driver returns from
+// connecting if the DSN has timeout parameter.
+type mysqlConnector struct {
+	driver.Connector
+}
+
+// mysqlPingKey is the key of the context of a ping in a context given to
+// mysqlDial.
+type mysqlPingKey struct{}
+
+func (c mysqlConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.Connector.Connect(context.WithValue(ctx, mysqlPingKey{}, ctx))
+}
+
+// mysqlDial dials addr, and closes the connection once the ping is over, so
+// that no connection of the driver outlives Ping.
+func mysqlDial(ctx context.Context, network, addr string) (net.Conn, error) {
+	pingCtx, ok := ctx.Value(mysqlPingKey{}).(context.Context)
+	if !ok {
+		pingCtx = ctx
+	}
+
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := pingCtx.Deadline(); ok {
+		conn.SetDeadline(t)
+	}
+
+	c := &mysqlNetConn{Conn: conn, closed: make(chan struct{})}
+	go func() {
+		select {
+		case <-pingCtx.Done():
+			c.Close()
+		case <-c.closed:
+		}
+	}()
+	return c, nil
+}
+
+// mysqlNetConn is conn closed by the context of a ping. Close can be called
+// twice, not to make the driver log an error when it closes a connection
+// closed by the context.
+type mysqlNetConn struct {
+	net.Conn
+	closed chan struct{}
+	once   sync.Once
+	err    error
+}
+
+func (c *mysqlNetConn) Close() error {
+	c.once.Do(func() {
+		c.err = c.Conn.Close()
+		close(c.closed)
+	})
+	return c.err
+}
+
+// urlToDSN converts u to a DSN of the MySQL driver. socket parameter gives
+// the path of a unix socket instead of the host.
+//
+// The username and the password are put into the DSN as they are, because
+// the driver does not unescape them.
+func urlToDSN(pu *url.URL) (string, error) {
+	u := *pu
+
+	addr := "tcp(" + u.Host + ")"
+	q := u.Query()
+	if socket := q.Get("socket"); socket != "" {
+		addr = "unix(" + socket + ")"
+		q.Del("socket")
+		u.RawQuery = q.Encode()
+	}
+
+	dsn := addr + "/"
+	if u.RawQuery != "" {
+		dsn += "?" + u.RawQuery
+	}
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+
+	config.DBName = strings.TrimPrefix(u.Path, "/")
+	if u.User != nil {
+		config.User = u.User.Username()
+		config.Passwd, _ = u.User.Password()
+	}
+	return config.FormatDSN(), nil
 }
@@ -0,0 +1,223 @@
+package png
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// mysqlChecks are the checks of the replication of a MySQL server, given by
+// query parameters.
+type mysqlChecks struct {
+	// replica requires the IO and SQL threads of the replica are running.
+	replica bool
+	// maxLag is the maximum of Seconds_Behind_Source, checked when checkLag
+	// is true.
+	maxLag   time.Duration
+	checkLag bool
+
+	// readOnly and superReadOnly are the expected values of the variables,
+	// or nil for no check.
+	readOnly      *bool
+	superReadOnly *bool
+}
+
+// parseMySQLChecks moves the replication parameters of u into mysqlChecks.
+func parseMySQLChecks(u *url.URL) (mysqlChecks, error) {
+	var c mysqlChecks
+
+	params := takeParams(u, "replica", "max_lag", "read_only", "super_read_only")
+	if len(params) == 0 {
+		return c, nil
+	}
+
+	if v, ok := params["replica"]; ok {
+		replica, err := strconv.ParseBool(v)
+		if err != nil {
+			return c, errors.Wrapf(err, "invalid replica: %#v", v)
+		}
+		c.replica = replica
+	}
+
+	if v, ok := params["max_lag"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, errors.Wrapf(err, "invalid max_lag: %#v", v)
+		}
+		c.maxLag, c.checkLag = d, true
+	}
+
+	for name, b := range map[string]**bool{"read_only": &c.readOnly, "super_read_only": &c.superReadOnly} {
+		if v, ok := params[name]; ok {
+			value, err := strconv.ParseBool(v)
+			if err != nil {
+				return c, errors.Wrapf(err, "invalid %s: %#v", name, v)
+			}
+			*b = &value
+		}
+	}
+
+	return c, nil
+}
+
+// display returns u with the replication parameters of c.
+func (c mysqlChecks) display(u *url.URL) *url.URL {
+	if !c.replica && !c.checkLag && c.readOnly == nil && c.superReadOnly == nil {
+		return u
+	}
+
+	v := *u
+	q := v.Query()
+	if c.replica {
+		q.Set("replica", "true")
+	}
+	if c.checkLag {
+		q.Set("max_lag", c.maxLag.String())
+	}
+	if c.readOnly != nil {
+		q.Set("read_only", strconv.FormatBool(*c.readOnly))
+	}
+	if c.superReadOnly != nil {
+		q.Set("super_read_only", strconv.FormatBool(*c.superReadOnly))
+	}
+	v.RawQuery = q.Encode()
+	return &v
+}
+
+// check reads the replica status and the variables on db, and checks them
+// by c.
+func (c mysqlChecks) check(ctx context.Context, db *sql.DB) error {
+	if c.replica || c.checkLag {
+		if err := c.checkReplica(ctx, db); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range []struct {
+		name  string
+		value *bool
+	}{
+		{"read_only", c.readOnly},
+		{"super_read_only", c.superReadOnly},
+	} {
+		if v.value == nil {
+			continue
+		}
+
+		var value sql.NullString
+		if err := db.QueryRowContext(ctx, "SELECT @@global."+v.name).Scan(&value); err != nil {
+			return errors.Wrapf(err, "failed in reading %s", v.name)
+		}
+		report(ctx, "mysql_"+v.name, value.String)
+
+		actual := value.String == "1" || value.String == "ON"
+		if actual != *v.value {
+			return errors.Errorf("%s is %s (expected %s)", v.name, onOff(actual), onOff(*v.value))
+		}
+	}
+
+	return nil
+}
+
+func (c mysqlChecks) checkReplica(ctx context.Context, db *sql.DB) error {
+	// SHOW REPLICA STATUS is MySQL 8.0.22 or later.
+	status, err := mysqlReplicaStatus(ctx, db, "SHOW REPLICA STATUS")
+	if err != nil {
+		status, err = mysqlReplicaStatus(ctx, db, "SHOW SLAVE STATUS")
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed in reading replica status")
+	}
+	if status == nil {
+		return errors.New("not a replica (no replica status)")
+	}
+
+	// Columns are named "Slave" and "Master" before MySQL 8.0.22.
+	column := func(name, old string) string {
+		if v, ok := status[name]; ok {
+			return v.String
+		}
+		return status[old].String
+	}
+
+	ioThread := column("Replica_IO_Running", "Slave_IO_Running")
+	sqlThread := column("Replica_SQL_Running", "Slave_SQL_Running")
+	report(ctx, "mysql_replica_io", ioThread)
+	report(ctx, "mysql_replica_sql", sqlThread)
+
+	if ioThread != "Yes" {
+		return errors.Errorf("IO thread is not running: %s (Last_IO_Error: %#v)", ioThread, status["Last_IO_Error"].String)
+	}
+	if sqlThread != "Yes" {
+		return errors.Errorf("SQL thread is not running: %s (Last_SQL_Error: %#v)", sqlThread, status["Last_SQL_Error"].String)
+	}
+
+	lag, ok := status["Seconds_Behind_Source"]
+	if !ok {
+		lag = status["Seconds_Behind_Master"]
+	}
+	if !lag.Valid {
+		report(ctx, "mysql_replica_lag", "unknown")
+		if c.checkLag {
+			return errors.New("replication lag is unknown")
+		}
+		return nil
+	}
+
+	seconds, err := strconv.Atoi(lag.String)
+	if err != nil {
+		return errors.Errorf("invalid replication lag: %#v", lag.String)
+	}
+	d := time.Duration(seconds) * time.Second
+	report(ctx, "mysql_replica_lag", d.String())
+
+	if c.checkLag && d > c.maxLag {
+		return errors.Errorf("replication lag is %s (more than %s)", d, c.maxLag)
+	}
+	return nil
+}
+
+// mysqlReplicaStatus returns the first row of query by column names, or nil
+// when no row is returned.
+func mysqlReplicaStatus(ctx context.Context, db *sql.DB, query string) (map[string]sql.NullString, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	status := make(map[string]sql.NullString)
+	for i, name := range columns {
+		status[name] = values[i]
+	}
+	return status, nil
+}
+
+func onOff(b bool) string {
+	if b {
+		return "ON"
+	}
+	return "OFF"
+}
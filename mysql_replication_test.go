@@ -0,0 +1,102 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestMySQLChecks(t *testing.T) {
+	ping := func(s *pngtest.MySQLServer, params string) error {
+		p, err := Parse(s.URL + params)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+		return p.Ping(context.Background())
+	}
+
+	t.Run("Replica", func(t *testing.T) {
+		s := pngtest.NewMySQLServer()
+		defer s.Close()
+
+		status := &pngtest.Result{
+			Columns: []string{"Replica_IO_Running", "Replica_SQL_Running", "Seconds_Behind_Source", "Last_IO_Error", "Last_SQL_Error"},
+			Rows:    [][]interface{}{{"Yes", "Yes", "3", "", ""}},
+		}
+		s.SetResult("SHOW REPLICA STATUS", status)
+
+		if err := ping(s, "?replica=true&max_lag=5s"); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		for _, c := range []struct {
+			row []interface{}
+			msg string
+		}{
+			{[]interface{}{"Yes", "Yes", "10", "", ""}, "replication lag is 10s (more than 5s)"},
+			{[]interface{}{"Yes", "Yes", nil, "", ""}, "replication lag is unknown"},
+			{[]interface{}{"Connecting", "Yes", nil, "error connecting to source", ""}, "IO thread is not running: Connecting (Last_IO_Error: \"error connecting to source\")"},
+			{[]interface{}{"Yes", "No", nil, "", "duplicate entry"}, "SQL thread is not running: No (Last_SQL_Error: \"duplicate entry\")"},
+		} {
+			status.Rows[0] = c.row
+			err := ping(s, "?replica=true&max_lag=5s")
+			if err == nil {
+				t.Fatalf("succeeded to ping with %#v", c.row)
+			}
+			if msg := err.Error(); msg != "failed in MySQL replication check: "+c.msg {
+				t.Fatalf("unexpected error message: %#v", msg)
+			}
+		}
+	})
+
+	t.Run("Slave Status", func(t *testing.T) {
+		s := pngtest.NewMySQLServer()
+		defer s.Close()
+
+		s.SetResult("SHOW SLAVE STATUS", &pngtest.Result{
+			Columns: []string{"Slave_IO_Running", "Slave_SQL_Running", "Seconds_Behind_Master"},
+			Rows:    [][]interface{}{{"Yes", "Yes", "0"}},
+		})
+
+		if err := ping(s, "?max_lag=1s"); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+	})
+
+	t.Run("Not Replica", func(t *testing.T) {
+		s := pngtest.NewMySQLServer()
+		defer s.Close()
+
+		s.SetResult("SHOW REPLICA STATUS", &pngtest.Result{Columns: []string{"Replica_IO_Running"}})
+
+		err := ping(s, "?replica=true")
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "failed in MySQL replication check: not a replica (no replica status)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Read Only", func(t *testing.T) {
+		s := pngtest.NewMySQLServer()
+		defer s.Close()
+
+		s.SetResult("SELECT @@global.read_only", &pngtest.Result{Columns: []string{"@@global.read_only"}, Rows: [][]interface{}{{"1"}}})
+		s.SetResult("SELECT @@global.super_read_only", &pngtest.Result{Columns: []string{"@@global.super_read_only"}, Rows: [][]interface{}{{"0"}}})
+
+		if err := ping(s, "?read_only=true&super_read_only=false"); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		err := ping(s, "?read_only=false")
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "failed in MySQL replication check: read_only is ON (expected OFF)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
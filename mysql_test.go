@@ -11,6 +11,8 @@ import (
 	"path"
 	"strconv"
 	"strings"
+
+	"github.com/MakeNowJust/png/pngtest"
 )
 
 type MySQLServer struct {
@@ -101,3 +103,72 @@ func TestMySQLPinger(t *testing.T) {
 		}
 	})
 }
+
+func TestMySQLPingerUnixSocket(t *testing.T) {
+	s := pngtest.NewMySQLServer()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "png")
+	if err != nil {
+		t.Fatalf("failed in creating a directory: %+#v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := path.Join(dir, "mysqld.sock")
+	if err := s.ListenUnix(socket); err != nil {
+		t.Skipf("unix socket is not available: %v", err)
+	}
+
+	for _, rawurl := range []string{
+		"mysql://" + pngtest.User + ":" + pngtest.Password + "@/png?socket=" + url.QueryEscape(socket),
+		"mysql+unix://" + pngtest.User + ":" + pngtest.Password + "@" + url.PathEscape(socket) + "/png",
+	} {
+		p, err := Parse(rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping() of %s: %+#v", rawurl, err)
+		}
+	}
+}
+
+func TestMySQLPingerSkipVerify(t *testing.T) {
+	ca := pngtest.NewCA()
+	s := pngtest.NewMySQLServer()
+	defer s.Close()
+	s.SetTLS(ca.ServerConfig(ca.Issue("example.com"), false))
+
+	p, err := Parse(s.URL + "?tls=skip-verify")
+	if err != nil {
+		t.Fatalf("failed in Parse(): %+#v", err)
+	}
+
+	if err := p.Ping(context.Background()); err != nil {
+		t.Fatalf("failed in p.Ping(): %+#v", err)
+	}
+
+	p, err = Parse(s.URL + "?tls=true")
+	if err != nil {
+		t.Fatalf("failed in Parse(): %+#v", err)
+	}
+
+	if err := p.Ping(context.Background()); err == nil {
+		t.Fatal("succeeded to ping with an unknown CA")
+	}
+}
+
+func TestMySQLPingerTimeout(t *testing.T) {
+	s := pngtest.NewMySQLServer()
+	defer s.Close()
+
+	p, err := Parse(s.URL + "?timeout=5s")
+	if err != nil {
+		t.Fatalf("failed in Parse(): %+#v", err)
+	}
+
+	if err := p.Ping(context.Background()); err != nil {
+		t.Fatalf("failed in p.Ping(): %+#v", err)
+	}
+}
@@ -1,22 +1,36 @@
 package png
 
 import (
+	"net"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 )
 
 func Parse(rawurl string) (Pinger, error) {
+	return ParseWithDefaults(rawurl, nil)
+}
+
+// ParseWithDefaults is like Parse, but defaults give the values of query
+// parameters missing in rawurl, like `tls_ca`. They are also given to the
+// children of groups.
+func ParseWithDefaults(rawurl string, defaults url.Values) (Pinger, error) {
 	if rawurl == "" {
 		return nil, errors.New("invalid URL: \"\" (empty)")
 	}
 
-	u, err := parseURL(rawurl)
+	u, err := parseURL(moveSocketHost(rawurl))
 	if err != nil {
 		return nil, err
 	}
 
+	switch u.Scheme {
+	case "all", "any", "quorum":
+		return parseGroup(u, defaults)
+	}
+
 	// When hostname is not specified, sets `127.0.0.1`.
 	if u.Hostname() == "" {
 		host := "127.0.0.1"
@@ -26,15 +40,25 @@ func Parse(rawurl string) (Pinger, error) {
 		u.Host = host
 	}
 
+	if noTLSSchemes[u.Scheme] {
+		if err := rejectTLSParams(u); err != nil {
+			return nil, err
+		}
+	}
+	tlsOpts, err := parseTLSOptions(u, defaults)
+	if err != nil {
+		return nil, err
+	}
+
 	switch u.Scheme {
 	case "http":
 		fallthrough
 	case "https":
-		return &HTTPPinger{url: u}, nil
+		return &HTTPPinger{url: u, tls: tlsOpts}, nil
 	case "ws":
 		fallthrough
 	case "wss":
-		return &WebSocketPinger{url: u}, nil
+		return &WebSocketPinger{url: u, tls: tlsOpts}, nil
 
 	case "tcp":
 		fallthrough
@@ -43,26 +67,82 @@ func Parse(rawurl string) (Pinger, error) {
 	case "tcp6":
 		return &TCPPinger{network: u.Scheme, addr: u.Host}, nil
 
-	case "mysql":
-		if port := u.Port(); port == "" {
-			u.Host = u.Hostname() + ":3306"
+	case "udp", "udp4", "udp6":
+		return parseUDP(u)
+
+	case "icmp", "icmp6":
+		if u.Port() != "" {
+			return nil, errors.Errorf("invalid %s URL: %#v (no port in ICMP)", u.Scheme, u.Host)
+		}
+		return &ICMPPinger{scheme: u.Scheme, host: u.Hostname()}, nil
+
+	case "dns":
+		if u.Port() == "" {
+			u.Host += ":53"
+		}
+		query, err := parseDNSQuery(u, strings.TrimPrefix(u.Path, "/"))
+		if err != nil {
+			return nil, err
 		}
-		return &MySQLPinger{url: u}, nil
+		return &DNSPinger{addr: u.Host, query: query}, nil
 
-	case "postgres":
-		if u.RawQuery == "" {
-			u.RawQuery = "sslmode=disable"
+	case "dot":
+		if u.Port() == "" {
+			u.Host += ":853"
+		}
+		query, err := parseDNSQuery(u, strings.TrimPrefix(u.Path, "/"))
+		if err != nil {
+			return nil, err
 		}
-		if u.Path == "/" {
-			u.Path = "/postgres"
+		return &DoTPinger{addr: u.Host, tls: tlsOpts, query: query}, nil
+
+	case "doh":
+		return parseDoH(u, tlsOpts)
+
+	case "tls":
+		if u.Port() == "" {
+			u.Host += ":443"
 		}
-		return &PostgresPinger{url: u}, nil
+		return &TLSPinger{addr: u.Host, tls: tlsOpts}, nil
+
+	case "mysql", "mysql+unix":
+		return parseMySQL(u, tlsOpts)
+
+	case "postgres", "postgresql":
+		return parsePostgres(u, tlsOpts)
 
 	case "redis":
-		return parseRedis(u)
+		fallthrough
+	case "rediss":
+		return parseRedis(u, tlsOpts)
+	case "redis-sentinel":
+		return parseRedisSentinel(u, tlsOpts)
+	case "redis-cluster":
+		return parseRedisCluster(u, tlsOpts)
+
+	case "amqp", "amqps":
+		password, err := parseSecret(u)
+		if err != nil {
+			return nil, err
+		}
+		checks, err := parseAMQPChecks(u)
+		if err != nil {
+			return nil, err
+		}
 
-	case "amqp":
-		return &AMQPPinger{url: u}, nil
+		// amqps:// is always over TLS.
+		if u.Scheme == "amqps" {
+			tlsOpts.set = true
+			if u.Port() == "" {
+				u.Host += ":5671"
+			}
+		}
+
+		p := &AMQPPinger{url: u, password: password, tls: tlsOpts, checks: checks}
+		if err := parseAMQPConfig(u, p); err != nil {
+			return nil, err
+		}
+		return p, nil
 
 	default:
 		return nil, errors.Errorf("unknown scheme: %s", u.Scheme)
@@ -70,45 +150,346 @@ func Parse(rawurl string) (Pinger, error) {
 }
 
 func parseURL(rawurl string) (u *url.URL, err error) {
-	u, err = url.Parse(rawurl)
+	u, err = url.Parse(escapeUserinfo(rawurl))
 	if err != nil {
-		err = errors.Wrapf(err, "failed in parsing URL: %#v", rawurl)
+		err = errors.Wrapf(redactParseError(err), "failed in parsing URL: %#v", redactURL(rawurl))
 	} else {
 		// - `u.Opaque != ""` is for `localhost:8080` case.
 		// - `u.Scheme != ""` is for `localhost` case.
 		if u.Opaque != "" || u.Scheme == "" {
 			rawurl = "http://" + rawurl
 
-			u, err = url.Parse(rawurl)
-			err = errors.Wrapf(err, "failed in parsing URL: %#v", rawurl)
+			u, err = url.Parse(escapeUserinfo(rawurl))
+			err = errors.Wrapf(redactParseError(err), "failed in parsing URL: %#v", redactURL(rawurl))
 		}
 	}
 
 	return
 }
 
-func parseRedis(u *url.URL) (Pinger, error) {
-	var password string
+// redactParseError hides the password in the URL of an error of url.Parse.
+func redactParseError(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return &url.Error{Op: e.Op, URL: redactURL(e.URL), Err: e.Err}
+	}
+	return err
+}
+
+func parseMySQL(u *url.URL, tlsOpts tlsOptions) (Pinger, error) {
+	password, err := parseSecret(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := parseQueryCheck(u)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := parseMySQLChecks(u)
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+
+	// tls parameter selects a TLS mode of the driver, which is done by
+	// tlsOptions except for `preferred`.
+	if v, ok := q["tls"]; ok {
+		mode := v[0]
+		switch mode {
+		case "true", "skip-verify":
+			tlsOpts.set = true
+			tlsOpts.insecure = tlsOpts.insecure || mode == "skip-verify"
+			q.Del("tls")
+		case "false", "preferred":
+			if tlsOpts.set {
+				return nil, errors.Errorf("tls=%s cannot be used with tls_* parameters", mode)
+			}
+		default:
+			return nil, errors.Errorf("invalid tls: %#v (must be true, false, skip-verify or preferred)", mode)
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	if q.Get("socket") != "" {
+		u.Host = ""
+	} else if u.Scheme == "mysql+unix" {
+		return nil, errors.New("no socket in mysql+unix URL")
+	} else if port := u.Port(); port == "" {
+		u.Host = u.Hostname() + ":3306"
+	}
+
+	return &MySQLPinger{url: u, password: password, tls: tlsOpts, query: query, checks: checks}, nil
+}
+
+func parsePostgres(u *url.URL, tlsOpts tlsOptions) (Pinger, error) {
+	password, err := parseSecret(u)
+	if err != nil {
+		return nil, err
+	}
+	query, err := parseQueryCheck(u)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := parsePostgresChecks(u)
+	if err != nil {
+		return nil, err
+	}
+
+	// The other parameters are passed to lib/pq as they are. sslmode is
+	// disabled by default only without them, as before they were passed, and
+	// on unix sockets, where libpq does not use TLS.
+	q := u.Query()
+	if _, ok := q["sslmode"]; ok {
+		if tlsOpts.set {
+			// TLS of the parameters is started by png, instead of lib/pq.
+			return nil, errors.New("sslmode cannot be used with tls_* parameters")
+		}
+	} else if len(q) == 0 || strings.HasPrefix(q.Get("host"), "/") {
+		q.Set("sslmode", "disable")
+		u.RawQuery = q.Encode()
+	}
+
+	// host parameter, like a unix socket directory, overrides the host of u.
+	if _, ok := q["host"]; ok {
+		port := u.Port()
+		u.Host = ""
+		if port != "" {
+			u.Host = ":" + port
+		}
+	}
+
+	return &PostgresPinger{url: u, password: password, tls: tlsOpts, query: query, checks: checks}, nil
+}
+
+// socketParams are the parameters of unix sockets by scheme.
+var socketParams = map[string]string{
+	"postgres":   "host",
+	"postgresql": "host",
+	"mysql":      "socket",
+	"mysql+unix": "socket",
+}
+
+// moveSocketHost moves the host of a PostgreSQL or MySQL URL beginning with
+// `%2F` to the socket parameter, because url.Parse rejects it. It is a unix
+// socket like `postgresql://%2Fvar%2Frun%2Fpostgresql/db` of libpq.
+func moveSocketHost(rawurl string) string {
+	i := strings.Index(rawurl, "://")
+	if i < 0 {
+		return rawurl
+	}
+	name, ok := socketParams[rawurl[:i]]
+	if !ok {
+		return rawurl
+	}
+
+	rest := rawurl[i+len("://"):]
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	authority, tail := rest[:end], rest[end:]
+
+	userinfo, hostport := "", authority
+	if j := strings.LastIndex(authority, "@"); j >= 0 {
+		userinfo, hostport = authority[:j+1], authority[j+1:]
+	}
+	if !strings.HasPrefix(strings.ToUpper(hostport), "%2F") {
+		return rawurl
+	}
+
+	host, port := hostport, ""
+	if j := strings.LastIndex(hostport, ":"); j >= 0 {
+		host, port = hostport[:j], hostport[j:]
+	}
+	dir, err := url.PathUnescape(host)
+	if err != nil {
+		return rawurl
+	}
+
+	param := name + "=" + url.QueryEscape(dir)
+	if j := strings.Index(tail, "?"); j >= 0 {
+		tail = tail[:j+1] + param + "&" + tail[j+1:]
+	} else {
+		tail += "?" + param
+	}
+
+	return rawurl[:i+len("://")] + userinfo + port + tail
+}
+
+func parseRedis(u *url.URL, tlsOpts tlsOptions) (Pinger, error) {
+	p, err := parseRedisNode(u, tlsOpts, u.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	p.addr = splitHosts(u.Host, "6379")[0]
+	return p, nil
+}
+
+// parseRedisNode returns a RedisPinger without the address, to connect to
+// Redis nodes with the credentials, the TLS options of u, and the db number
+// in path.
+func parseRedisNode(u *url.URL, tlsOpts tlsOptions, path string) (*RedisPinger, error) {
+	var username string
 	var db int
 
+	// The username is the ACL user of Redis 6 or later.
 	if user := u.User; user != nil {
-		password, _ = user.Password()
-		// TODO: should we treat username as password? It maybe useful but it maybe break consistent.
+		username = user.Username()
 	}
 
-	if path := u.Path; len(path) >= 2 {
+	password, err := parseSecret(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(path) >= 2 {
 		path = path[1:] // skip `/`
 
-		var err error
 		db, err = strconv.Atoi(path)
 		if err != nil {
 			return nil, errors.Wrapf(err, "invalid db number: %#v", path)
 		}
 	}
 
+	checks, err := parseRedisChecks(u)
+	if err != nil {
+		return nil, err
+	}
+
+	// rediss:// is always over TLS.
+	if u.Scheme == "rediss" {
+		tlsOpts.set = true
+	}
+
 	return &RedisPinger{
-		addr:     u.Host,
+		scheme:   u.Scheme,
+		username: username,
 		password: password,
 		db:       db,
+		tls:      tlsOpts,
+		checks:   checks,
+	}, nil
+}
+
+func parseRedisSentinel(u *url.URL, tlsOpts tlsOptions) (Pinger, error) {
+	// The path is `/MASTER` or `/MASTER/DB`.
+	path := strings.TrimPrefix(u.Path, "/")
+	master, db := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		master, db = path[:i], path[i:]
+	}
+
+	if master == "" {
+		return nil, errors.New("no master name in redis-sentinel URL")
+	}
+
+	// sentinel_password is the password of the sentinels, which may refer
+	// environment variables like the password of the master.
+	var password secret
+	if q := u.Query(); q.Get("sentinel_password") != "" {
+		password.raw = q.Get("sentinel_password")
+		q.Del("sentinel_password")
+		u.RawQuery = q.Encode()
+	}
+
+	node, err := parseRedisNode(u, tlsOpts, db)
+	if err != nil {
+		return nil, err
+	}
+	node.scheme = "redis"
+
+	return &RedisSentinelPinger{
+		sentinels: splitHosts(u.Host, "26379"),
+		master:    master,
+		password:  password,
+		node:      node,
+	}, nil
+}
+
+func parseRedisCluster(u *url.URL, tlsOpts tlsOptions) (Pinger, error) {
+	if u.Path != "" && u.Path != "/" {
+		return nil, errors.Errorf("invalid path of redis-cluster URL: %#v (no db number in cluster)", u.Path)
+	}
+
+	node, err := parseRedisNode(u, tlsOpts, "")
+	if err != nil {
+		return nil, err
+	}
+	node.scheme = "redis"
+
+	return &RedisClusterPinger{
+		nodes: splitHosts(u.Host, "6379"),
+		node:  node,
 	}, nil
 }
+
+// splitHosts splits comma-separated hosts, and fills the default port in.
+func splitHosts(hosts, port string) []string {
+	addrs := strings.Split(hosts, ",")
+	for i, addr := range addrs {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addrs[i] = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+		}
+	}
+	return addrs
+}
+
+func parseDoH(u *url.URL, tlsOpts tlsOptions) (Pinger, error) {
+	if u.Port() == "" {
+		u.Host += ":443"
+	}
+	query, err := parseDNSQuery(u, strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The path of u is the query name, so the path of the endpoint is given
+	// by path parameter.
+	q := u.Query()
+	path := "/dns-query"
+	if v, ok := q["path"]; ok {
+		path = v[0]
+		if !strings.HasPrefix(path, "/") {
+			return nil, errors.Errorf("invalid path: %#v (must begin with /)", path)
+		}
+		q.Del("path")
+		u.RawQuery = q.Encode()
+	}
+
+	return &DoHPinger{addr: u.Host, path: path, tls: tlsOpts, query: query}, nil
+}
+
+func parseGroup(u *url.URL, defaults url.Values) (Pinger, error) {
+	q := u.Query()
+
+	targets := q["target"]
+	if len(targets) == 0 {
+		return nil, errors.Errorf("no target in %s group", u.Scheme)
+	}
+
+	pingers := make([]Pinger, len(targets))
+	for i, target := range targets {
+		p, err := ParseWithDefaults(target, defaults)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid target in %s group", u.Scheme)
+		}
+		pingers[i] = p
+	}
+
+	switch u.Scheme {
+	case "all":
+		return All(pingers...), nil
+	case "any":
+		return Any(pingers...), nil
+	}
+
+	n, err := strconv.Atoi(q.Get("n"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid quorum: %#v", q.Get("n"))
+	}
+	if n < 1 || n > len(pingers) {
+		return nil, errors.Errorf("invalid quorum: %d (must be in 1 to %d)", n, len(pingers))
+	}
+	return Quorum(n, pingers...), nil
+}
@@ -0,0 +1,680 @@
+package pngtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xce
+
+	amqpFrameMax = 131072
+)
+
+// AMQP class and method IDs, as class<<16 | method.
+const (
+	amqpConnectionStart   = 10<<16 | 10
+	amqpConnectionStartOk = 10<<16 | 11
+	amqpConnectionTune    = 10<<16 | 30
+	amqpConnectionTuneOk  = 10<<16 | 31
+	amqpConnectionOpen    = 10<<16 | 40
+	amqpConnectionOpenOk  = 10<<16 | 41
+	amqpConnectionClose   = 10<<16 | 50
+	amqpConnectionCloseOk = 10<<16 | 51
+	amqpChannelOpen       = 20<<16 | 10
+	amqpChannelOpenOk     = 20<<16 | 11
+	amqpChannelClose      = 20<<16 | 40
+	amqpChannelCloseOk    = 20<<16 | 41
+	amqpExchangeDeclare   = 40<<16 | 10
+	amqpExchangeDeclareOk = 40<<16 | 11
+	amqpQueueDeclare      = 50<<16 | 10
+	amqpQueueDeclareOk    = 50<<16 | 11
+	amqpQueueDelete       = 50<<16 | 40
+	amqpQueueDeleteOk     = 50<<16 | 41
+	amqpBasicConsume      = 60<<16 | 20
+	amqpBasicConsumeOk    = 60<<16 | 21
+	amqpBasicCancel       = 60<<16 | 30
+	amqpBasicCancelOk     = 60<<16 | 31
+	amqpBasicPublish      = 60<<16 | 40
+	amqpBasicDeliver      = 60<<16 | 60
+)
+
+// AMQPServer is a fake AMQP 0-9-1 server.
+//
+// It accepts User and Password with PLAIN mechanism, and supports enough
+// methods to declare, publish to, consume from and delete queues. Queues
+// live only in the connection which declares them, except the queues set by
+// SetQueue, which are only declared passively.
+type AMQPServer struct {
+	*Server
+
+	// queues and exchanges are the ones existing in the server, guarded by
+	// mu of Server.
+	queues    map[string]AMQPQueue
+	exchanges map[string]bool
+	vhosts    map[string]bool
+	client    AMQPClient
+}
+
+// AMQPClient is what the last client told the server on connect.
+type AMQPClient struct {
+	// Properties are the client properties of string values, like
+	// "connection_name".
+	Properties map[string]string
+	Vhost      string
+	// Heartbeat is the heartbeat interval in seconds.
+	Heartbeat uint16
+}
+
+// AMQPQueue is the state of a queue set by SetQueue.
+type AMQPQueue struct {
+	Messages  int
+	Consumers int
+}
+
+// NewAMQPServer starts a fake AMQP server.
+func NewAMQPServer() *AMQPServer {
+	s := &AMQPServer{}
+	s.Server = newServer(func(addr string) string {
+		return "amqp://" + User + ":" + Password + "@" + addr + "/"
+	}, s.serve, false)
+	s.run()
+	return s
+}
+
+// SetQueue makes a queue named name exist with the state q.
+func (s *AMQPServer) SetQueue(name string, q AMQPQueue) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.queues == nil {
+		s.queues = make(map[string]AMQPQueue)
+	}
+	s.queues[name] = q
+}
+
+// AddExchange makes an exchange named name exist, in addition to the
+// default exchanges.
+func (s *AMQPServer) AddExchange(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.exchanges == nil {
+		s.exchanges = make(map[string]bool)
+	}
+	s.exchanges[name] = true
+}
+
+// AddVhost makes a virtual host named name exist, in addition to "/".
+func (s *AMQPServer) AddVhost(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vhosts == nil {
+		s.vhosts = make(map[string]bool)
+	}
+	s.vhosts[name] = true
+}
+
+// LastClient returns what the last client told the server on connect.
+func (s *AMQPServer) LastClient() AMQPClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.client
+}
+
+func (s *AMQPServer) hasVhost(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return name == "/" || s.vhosts[name]
+}
+
+func (s *AMQPServer) queue(name string) (AMQPQueue, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.queues[name]
+	return q, ok
+}
+
+func (s *AMQPServer) hasExchange(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch name {
+	case "", "amq.direct", "amq.fanout", "amq.topic", "amq.headers":
+		return true
+	}
+	return s.exchanges[name]
+}
+
+type amqpFrame struct {
+	typ     byte
+	channel uint16
+	payload []byte
+}
+
+type amqpMessage struct {
+	exchange   string
+	routingKey string
+	header     []byte
+	body       []byte
+}
+
+type amqpQueue struct {
+	messages []*amqpMessage
+	consumer string
+	channel  uint16
+}
+
+// amqpConn is the state of a client connection.
+type amqpConn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+
+	queues      map[string]*amqpQueue
+	publishing  map[uint16]*amqpMessage
+	deliveryTag uint64
+	serial      int
+}
+
+func (c *amqpConn) readFrame() (*amqpFrame, error) {
+	var header [7]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[3:])
+	if size > amqpFrameMax {
+		return nil, errors.New("too large frame")
+	}
+
+	f := &amqpFrame{
+		typ:     header[0],
+		channel: binary.BigEndian.Uint16(header[1:3]),
+		payload: make([]byte, size+1),
+	}
+	if _, err := io.ReadFull(c.r, f.payload); err != nil {
+		return nil, err
+	}
+
+	if f.payload[len(f.payload)-1] != amqpFrameEnd {
+		return nil, errors.New("invalid frame end")
+	}
+	f.payload = f.payload[:len(f.payload)-1]
+
+	return f, nil
+}
+
+func (c *amqpConn) writeFrame(typ byte, channel uint16, payload []byte) {
+	var header [7]byte
+	header[0] = typ
+	binary.BigEndian.PutUint16(header[1:3], channel)
+	binary.BigEndian.PutUint32(header[3:], uint32(len(payload)))
+
+	c.w.Write(header[:])
+	c.w.Write(payload)
+	c.w.WriteByte(amqpFrameEnd)
+}
+
+func (c *amqpConn) writeMethod(channel uint16, method uint32, args *amqpBuffer) {
+	b := &amqpBuffer{}
+	b.long(method)
+	if args != nil {
+		b.Write(args.Bytes())
+	}
+	c.writeFrame(amqpFrameMethod, channel, b.Bytes())
+}
+
+// readMethod reads a method frame skipping heartbeats.
+func (c *amqpConn) readMethod() (channel uint16, method uint32, args *amqpReader, err error) {
+	for {
+		var f *amqpFrame
+		if f, err = c.readFrame(); err != nil {
+			return
+		}
+
+		if f.typ == amqpFrameHeartbeat {
+			continue
+		}
+		if f.typ != amqpFrameMethod || len(f.payload) < 4 {
+			err = fmt.Errorf("unexpected frame type: %d", f.typ)
+			return
+		}
+
+		return f.channel, binary.BigEndian.Uint32(f.payload), &amqpReader{data: f.payload[4:]}, nil
+	}
+}
+
+func (s *AMQPServer) serve(conn net.Conn) {
+	c := &amqpConn{
+		r:          bufio.NewReader(conn),
+		w:          bufio.NewWriter(conn),
+		queues:     make(map[string]*amqpQueue),
+		publishing: make(map[uint16]*amqpMessage),
+	}
+
+	var protocol [8]byte
+	if _, err := io.ReadFull(c.r, protocol[:]); err != nil {
+		return
+	}
+	if string(protocol[:]) != "AMQP\x00\x00\x09\x01" {
+		conn.Write([]byte("AMQP\x00\x00\x09\x01"))
+		return
+	}
+
+	if !s.handshake(c) {
+		return
+	}
+
+	for {
+		if err := c.w.Flush(); err != nil {
+			return
+		}
+
+		f, err := c.readFrame()
+		if err != nil {
+			return
+		}
+
+		switch f.typ {
+		case amqpFrameMethod:
+			if len(f.payload) < 4 {
+				return
+			}
+			method := binary.BigEndian.Uint32(f.payload)
+			if !s.method(c, f.channel, method, &amqpReader{data: f.payload[4:]}) {
+				return
+			}
+		case amqpFrameHeader, amqpFrameBody:
+			s.content(c, f)
+		}
+	}
+}
+
+func (s *AMQPServer) handshake(c *amqpConn) bool {
+	props := &amqpBuffer{}
+	props.shortstr("product")
+	props.WriteByte('S')
+	props.longstr("pngtest")
+	props.shortstr("version")
+	props.WriteByte('S')
+	props.longstr("0.0.0")
+
+	start := &amqpBuffer{}
+	start.WriteByte(0) // version-major
+	start.WriteByte(9) // version-minor
+	start.table(props.Bytes())
+	start.longstr("PLAIN")
+	start.longstr("en_US")
+	c.writeMethod(0, amqpConnectionStart, start)
+	if err := c.w.Flush(); err != nil {
+		return false
+	}
+
+	_, method, args, err := c.readMethod()
+	if err != nil || method != amqpConnectionStartOk {
+		return false
+	}
+	client := AMQPClient{Properties: amqpStringTable(args.table())}
+	mechanism := args.shortstr()
+	response := args.longstr()
+
+	var user, password string
+	if creds := strings.Split(response, "\x00"); mechanism == "PLAIN" && len(creds) == 3 {
+		user, password = creds[1], creds[2]
+	}
+
+	if !s.authenticate(user, password) {
+		s.closeConnection(c, 403, "ACCESS_REFUSED - Login was refused using authentication mechanism "+mechanism)
+		return false
+	}
+
+	tune := &amqpBuffer{}
+	tune.short(2047)        // channel-max
+	tune.long(amqpFrameMax) // frame-max
+	tune.short(0)           // heartbeat
+	c.writeMethod(0, amqpConnectionTune, tune)
+	if err := c.w.Flush(); err != nil {
+		return false
+	}
+
+	if _, method, args, err = c.readMethod(); err != nil || method != amqpConnectionTuneOk {
+		return false
+	}
+	args.short() // channel-max
+	args.long()  // frame-max
+	client.Heartbeat = args.short()
+
+	if _, method, args, err = c.readMethod(); err != nil || method != amqpConnectionOpen {
+		return false
+	}
+	client.Vhost = args.shortstr()
+
+	s.mu.Lock()
+	s.client = client
+	s.mu.Unlock()
+
+	if !s.hasVhost(client.Vhost) {
+		s.closeConnection(c, 530, "NOT_ALLOWED - vhost "+client.Vhost+" not found")
+		return false
+	}
+
+	openOk := &amqpBuffer{}
+	openOk.shortstr("")
+	c.writeMethod(0, amqpConnectionOpenOk, openOk)
+
+	return true
+}
+
+func (s *AMQPServer) closeConnection(c *amqpConn, code uint16, text string) {
+	args := &amqpBuffer{}
+	args.short(code)
+	args.shortstr(text)
+	args.short(0)
+	args.short(0)
+	c.writeMethod(0, amqpConnectionClose, args)
+	c.w.Flush()
+
+	// Waits for connection.close-ok.
+	c.readMethod()
+}
+
+// method handles a method frame, and reports whether the connection is alive.
+func (s *AMQPServer) method(c *amqpConn, channel uint16, method uint32, args *amqpReader) bool {
+	switch method {
+	case amqpConnectionClose:
+		c.writeMethod(0, amqpConnectionCloseOk, nil)
+		c.w.Flush()
+		return false
+
+	case amqpChannelOpen:
+		reserved := &amqpBuffer{}
+		reserved.longstr("")
+		c.writeMethod(channel, amqpChannelOpenOk, reserved)
+
+	case amqpChannelClose:
+		c.writeMethod(channel, amqpChannelCloseOk, nil)
+
+	case amqpChannelCloseOk:
+
+	case amqpExchangeDeclare:
+		args.short() // reserved
+		name := args.shortstr()
+		args.shortstr() // type
+		passive := args.octet()&1 != 0
+
+		if passive && !s.hasExchange(name) {
+			s.closeChannel(c, channel, 404, "NOT_FOUND - no exchange '"+name+"' in vhost '/'", method)
+			return true
+		}
+		c.writeMethod(channel, amqpExchangeDeclareOk, nil)
+
+	case amqpQueueDeclare:
+		args.short() // reserved
+		name := args.shortstr()
+		passive := args.octet()&1 != 0
+		if name == "" {
+			c.serial++
+			name = fmt.Sprintf("amq.gen-%d", c.serial)
+		}
+
+		if state, ok := s.queue(name); ok {
+			declareOk := &amqpBuffer{}
+			declareOk.shortstr(name)
+			declareOk.long(uint32(state.Messages))
+			declareOk.long(uint32(state.Consumers))
+			c.writeMethod(channel, amqpQueueDeclareOk, declareOk)
+			return true
+		}
+
+		q, ok := c.queues[name]
+		if !ok && passive {
+			s.closeChannel(c, channel, 404, "NOT_FOUND - no queue '"+name+"' in vhost '/'", method)
+			return true
+		}
+		if !ok {
+			q = &amqpQueue{}
+			c.queues[name] = q
+		}
+
+		declareOk := &amqpBuffer{}
+		declareOk.shortstr(name)
+		declareOk.long(uint32(len(q.messages)))
+		if q.consumer != "" {
+			declareOk.long(1)
+		} else {
+			declareOk.long(0)
+		}
+		c.writeMethod(channel, amqpQueueDeclareOk, declareOk)
+
+	case amqpQueueDelete:
+		args.short() // reserved
+		name := args.shortstr()
+
+		var n int
+		if q, ok := c.queues[name]; ok {
+			n = len(q.messages)
+			delete(c.queues, name)
+		}
+
+		deleteOk := &amqpBuffer{}
+		deleteOk.long(uint32(n))
+		c.writeMethod(channel, amqpQueueDeleteOk, deleteOk)
+
+	case amqpBasicPublish:
+		args.short() // reserved
+		c.publishing[channel] = &amqpMessage{
+			exchange:   args.shortstr(),
+			routingKey: args.shortstr(),
+		}
+
+	case amqpBasicConsume:
+		args.short() // reserved
+		name := args.shortstr()
+		tag := args.shortstr()
+		if tag == "" {
+			c.serial++
+			tag = fmt.Sprintf("ctag-%d", c.serial)
+		}
+
+		q, ok := c.queues[name]
+		if !ok {
+			s.closeChannel(c, channel, 404, "NOT_FOUND - no queue '"+name+"'", method)
+			return true
+		}
+		q.consumer, q.channel = tag, channel
+
+		consumeOk := &amqpBuffer{}
+		consumeOk.shortstr(tag)
+		c.writeMethod(channel, amqpBasicConsumeOk, consumeOk)
+		s.deliver(c, q)
+
+	case amqpBasicCancel:
+		tag := args.shortstr()
+		for _, q := range c.queues {
+			if q.consumer == tag {
+				q.consumer = ""
+			}
+		}
+
+		cancelOk := &amqpBuffer{}
+		cancelOk.shortstr(tag)
+		c.writeMethod(channel, amqpBasicCancelOk, cancelOk)
+
+	default:
+		s.closeChannel(c, channel, 540, "NOT_IMPLEMENTED - pngtest does not support this method", method)
+	}
+
+	return true
+}
+
+func (s *AMQPServer) closeChannel(c *amqpConn, channel uint16, code uint16, text string, method uint32) {
+	args := &amqpBuffer{}
+	args.short(code)
+	args.shortstr(text)
+	args.long(method)
+	c.writeMethod(channel, amqpChannelClose, args)
+}
+
+// content handles content header and body frames of a published message.
+func (s *AMQPServer) content(c *amqpConn, f *amqpFrame) {
+	m, ok := c.publishing[f.channel]
+	if !ok {
+		return
+	}
+
+	if f.typ == amqpFrameHeader {
+		m.header = f.payload
+	} else {
+		m.body = append(m.body, f.payload...)
+	}
+
+	if len(m.header) < 12 || uint64(len(m.body)) < binary.BigEndian.Uint64(m.header[4:12]) {
+		return
+	}
+	delete(c.publishing, f.channel)
+
+	// Only the default exchange is supported, which routes to the queue
+	// named by the routing key.
+	if q, ok := c.queues[m.routingKey]; ok && m.exchange == "" {
+		q.messages = append(q.messages, m)
+		s.deliver(c, q)
+	}
+}
+
+func (s *AMQPServer) deliver(c *amqpConn, q *amqpQueue) {
+	if q.consumer == "" {
+		return
+	}
+
+	for _, m := range q.messages {
+		c.deliveryTag++
+
+		args := &amqpBuffer{}
+		args.shortstr(q.consumer)
+		args.longlong(c.deliveryTag)
+		args.WriteByte(0) // redelivered
+		args.shortstr(m.exchange)
+		args.shortstr(m.routingKey)
+		c.writeMethod(q.channel, amqpBasicDeliver, args)
+		c.writeFrame(amqpFrameHeader, q.channel, m.header)
+		c.writeFrame(amqpFrameBody, q.channel, m.body)
+	}
+	q.messages = nil
+}
+
+// amqpBuffer encodes AMQP fields.
+type amqpBuffer struct {
+	bytes.Buffer
+}
+
+func (b *amqpBuffer) short(n uint16) {
+	binary.Write(b, binary.BigEndian, n)
+}
+
+func (b *amqpBuffer) long(n uint32) {
+	binary.Write(b, binary.BigEndian, n)
+}
+
+func (b *amqpBuffer) longlong(n uint64) {
+	binary.Write(b, binary.BigEndian, n)
+}
+
+func (b *amqpBuffer) shortstr(s string) {
+	b.WriteByte(byte(len(s)))
+	b.WriteString(s)
+}
+
+func (b *amqpBuffer) longstr(s string) {
+	b.long(uint32(len(s)))
+	b.WriteString(s)
+}
+
+func (b *amqpBuffer) table(fields []byte) {
+	b.long(uint32(len(fields)))
+	b.Write(fields)
+}
+
+// amqpStringTable decodes the fields of string values in a field table,
+// skipping the fields of booleans and nested tables.
+func amqpStringTable(data []byte) map[string]string {
+	r := &amqpReader{data: data}
+	m := make(map[string]string)
+	for len(r.data) > 0 {
+		name := r.shortstr()
+		switch r.octet() {
+		case 'S':
+			m[name] = r.longstr()
+		case 't':
+			r.octet()
+		case 'F':
+			r.table()
+		default:
+			return m
+		}
+	}
+	return m
+}
+
+// amqpReader decodes AMQP fields. It returns zero values after the end of
+// data instead of errors, because malformed methods are simply ignored.
+type amqpReader struct {
+	data []byte
+}
+
+func (r *amqpReader) next(n int) []byte {
+	if len(r.data) < n {
+		r.data = nil
+		return nil
+	}
+
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *amqpReader) octet() byte {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *amqpReader) short() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *amqpReader) long() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *amqpReader) shortstr() string {
+	return string(r.next(int(r.octet())))
+}
+
+func (r *amqpReader) longstr() string {
+	return string(r.next(int(r.long())))
+}
+
+func (r *amqpReader) table() []byte {
+	return r.next(int(r.long()))
+}
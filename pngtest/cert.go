@@ -0,0 +1,142 @@
+package pngtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+)
+
+// CA is a certificate authority issuing certificates for TLS of the fake
+// servers and their clients.
+type CA struct {
+	// CertPEM is the certificate of this CA in PEM.
+	CertPEM []byte
+
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Cert is a certificate issued by CA.
+type Cert struct {
+	tls.Certificate
+
+	// CertPEM and KeyPEM are the certificate and its private key in PEM.
+	CertPEM []byte
+	KeyPEM  []byte
+}
+
+// NewCA generates a new CA.
+func NewCA() *CA {
+	key := generateKey()
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber(),
+		Subject:               pkix.Name{CommonName: "pngtest CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to create a CA certificate: %v", err))
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to parse a CA certificate: %v", err))
+	}
+
+	return &CA{
+		CertPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		cert:    cert,
+		key:     key,
+	}
+}
+
+// Pool returns a certificate pool containing only this CA.
+func (ca *CA) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// Issue issues a certificate for hosts, which are IP addresses or DNS names.
+// It is usable both for servers and for clients, and valid for a year.
+func (ca *CA) Issue(hosts ...string) *Cert {
+	return ca.IssueUntil(time.Now().AddDate(1, 0, 0), hosts...)
+}
+
+// IssueUntil is like Issue, but the certificate expires at notAfter.
+func (ca *CA) IssueUntil(notAfter time.Time, hosts ...string) *Cert {
+	key := generateKey()
+
+	template := &x509.Certificate{
+		SerialNumber: serialNumber(),
+		Subject:      pkix.Name{CommonName: "pngtest"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to create a certificate: %v", err))
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to marshal a private key: %v", err))
+	}
+
+	return &Cert{
+		Certificate: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		CertPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:      pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// ServerConfig returns a TLS config of a server using cert. The server
+// requires a client certificate issued by ca when clientAuth is true.
+func (ca *CA) ServerConfig(cert *Cert, clientAuth bool) *tls.Config {
+	config := &tls.Config{Certificates: []tls.Certificate{cert.Certificate}}
+	if clientAuth {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = ca.Pool()
+	}
+	return config
+}
+
+func generateKey() *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to generate a key: %v", err))
+	}
+	return key
+}
+
+func serialNumber() *big.Int {
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to generate a serial number: %v", err))
+	}
+	return n
+}
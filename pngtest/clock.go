@@ -0,0 +1,116 @@
+package pngtest
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FakeClock is a png.Clock whose time moves only by Sleep and Add, so that
+// code using it runs without real sleeps and is deterministic.
+//
+// Sleep moves the time forward immediately, and functions registered by
+// AfterFunc are called synchronously when the time reaches them. It is
+// intended to be used from a single goroutine.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	when time.Time
+	f    func()
+}
+
+// NewFakeClock returns a FakeClock starting at 2017-01-01 00:00:00 UTC.
+func NewFakeClock() *FakeClock {
+	return &FakeClock{now: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// Add moves the time forward by d.
+func (c *FakeClock) Add(d time.Duration) {
+	c.advance(context.Background(), d)
+}
+
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.advance(ctx, d)
+}
+
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) func() bool {
+	if d <= 0 {
+		f()
+		return func() bool { return false }
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTimer{when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+
+	return func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		return c.remove(t)
+	}
+}
+
+// advance moves the time forward by d, firing timers on the way. It stops at
+// a timer after which ctx is done.
+func (c *FakeClock) advance(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	target := c.now.Add(d)
+
+	for {
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if !t.when.After(target) && (next == nil || t.when.Before(next.when)) {
+				next = t
+			}
+		}
+
+		if next == nil {
+			if target.After(c.now) {
+				c.now = target
+			}
+			c.mu.Unlock()
+			return nil
+		}
+
+		c.remove(next)
+		if next.when.After(c.now) {
+			c.now = next.when
+		}
+		c.mu.Unlock()
+
+		next.f()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		c.mu.Lock()
+	}
+}
+
+func (c *FakeClock) remove(t *fakeTimer) bool {
+	for i, u := range c.timers {
+		if u == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+package pngtest
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// DNSName is the name of the records served by DNSServer by default.
+const DNSName = "example.com"
+
+// DNSServer is a fake DNS resolver answering over UDP and TCP on the same
+// port.
+//
+// It answers the records set by SetRecords, `example.com. 300 IN A
+// 192.0.2.1` by default, and NXDOMAIN for the other names.
+type DNSServer struct {
+	// URL is the URL of this server for png.Parse, querying the A record of
+	// DNSName.
+	URL string
+	// Addr is the address this server listens on, like "127.0.0.1:12345".
+	Addr string
+
+	// DoTURL and DoHURL are the URLs of DNS over TLS and DNS over HTTPS of
+	// this server, set by StartTLS.
+	DoTURL string
+	DoHURL string
+
+	udp  *dns.Server
+	tcp  *dns.Server
+	dot  *dns.Server
+	doh  *http.Server
+	done chan struct{}
+
+	mu       sync.Mutex
+	records  []dns.RR
+	rcode    int
+	truncate bool
+	latency  time.Duration
+}
+
+// NewDNSServer starts a fake DNS resolver.
+func NewDNSServer() *DNSServer {
+	s := &DNSServer{rcode: -1, done: make(chan struct{})}
+	s.SetRecords(DNSName + ". 300 IN A 192.0.2.1")
+
+	l, pc := listenTCPAndUDP()
+	s.Addr = l.Addr().String()
+	s.URL = "dns://" + s.Addr + "/" + DNSName + "?type=A"
+
+	s.tcp = s.start(&dns.Server{Listener: l})
+	s.udp = s.start(&dns.Server{PacketConn: pc})
+
+	return s
+}
+
+// listenTCPAndUDP listens on the same loopback port for TCP and UDP.
+func listenTCPAndUDP() (net.Listener, net.PacketConn) {
+	var err error
+	for i := 0; i < 10; i++ {
+		var l net.Listener
+		l, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			break
+		}
+
+		var pc net.PacketConn
+		pc, err = net.ListenPacket("udp", l.Addr().String())
+		if err == nil {
+			return l, pc
+		}
+		l.Close()
+	}
+	panic(fmt.Sprintf("pngtest: failed to listen on a port: %v", err))
+}
+
+func (s *DNSServer) start(server *dns.Server) *dns.Server {
+	started := make(chan struct{})
+	server.Handler = dns.HandlerFunc(s.serve)
+	server.NotifyStartedFunc = func() { close(started) }
+
+	go server.ActivateAndServe()
+	<-started
+
+	return server
+}
+
+// StartTLS makes the server serve also DNS over TLS and DNS over HTTPS on
+// `/dns-query` with config, on other ports.
+func (s *DNSServer) StartTLS(config *tls.Config) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to listen on a port: %v", err))
+	}
+	s.DoTURL = "dot://" + l.Addr().String() + "/" + DNSName + "?type=A"
+	s.dot = s.start(&dns.Server{Listener: l, Net: "tcp-tls"})
+
+	l, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to listen on a port: %v", err))
+	}
+	s.DoHURL = "doh://" + l.Addr().String() + "/" + DNSName + "?type=A"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/dns-query", s.serveHTTP)
+	s.doh = &http.Server{Handler: mux, TLSConfig: config.Clone()}
+	go s.doh.ServeTLS(l, "", "")
+}
+
+// serveHTTP serves a query of RFC 8484 by GET or POST.
+func (s *DNSServer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var data []byte
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		data, err = base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+	case http.MethodPost:
+		if r.Header.Get("Content-Type") != "application/dns-message" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		data, err = ioutil.ReadAll(r.Body)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	req := new(dns.Msg)
+	if err == nil {
+		err = req.Unpack(data)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	m := s.answer(req, false)
+	if m == nil {
+		return
+	}
+	data, err = m.Pack()
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/dns-message")
+	w.Write(data)
+}
+
+// SetRecords replaces the records with records in the zone file format,
+// like `example.com. 300 IN A 192.0.2.1`.
+func (s *DNSServer) SetRecords(records ...string) {
+	rrs := make([]dns.RR, len(records))
+	for i, record := range records {
+		rr, err := dns.NewRR(record)
+		if err != nil {
+			panic(fmt.Sprintf("pngtest: invalid record %q: %v", record, err))
+		}
+		rrs[i] = rr
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = rrs
+}
+
+// SetRcode makes the server answer every query with rcode and no record, like
+// dns.RcodeServerFailure. A negative rcode restores the normal answers.
+func (s *DNSServer) SetRcode(rcode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rcode = rcode
+}
+
+// SetTruncate makes the server answer queries over UDP with the TC bit and no
+// record when truncate is true, so that clients retry over TCP.
+func (s *DNSServer) SetTruncate(truncate bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.truncate = truncate
+}
+
+// SetLatency delays every answer by d.
+func (s *DNSServer) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+}
+
+func (s *DNSServer) serve(w dns.ResponseWriter, req *dns.Msg) {
+	_, udp := w.RemoteAddr().(*net.UDPAddr)
+	if m := s.answer(req, udp); m != nil {
+		w.WriteMsg(m)
+	}
+}
+
+// answer returns the answer to req, or nil when the server is closed while
+// delaying it.
+func (s *DNSServer) answer(req *dns.Msg, udp bool) *dns.Msg {
+	s.mu.Lock()
+	records, rcode, truncate, latency := s.records, s.rcode, s.truncate, s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-s.done:
+			return nil
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.RecursionAvailable = true
+
+	switch {
+	case rcode >= 0:
+		m.Rcode = rcode
+	case truncate && udp:
+		m.Truncated = true
+	case len(req.Question) == 1:
+		q := req.Question[0]
+		found := false
+		for _, rr := range records {
+			h := rr.Header()
+			if !strings.EqualFold(h.Name, q.Name) {
+				continue
+			}
+			found = true
+			if h.Rrtype == q.Qtype || h.Rrtype == dns.TypeCNAME {
+				m.Answer = append(m.Answer, rr)
+			}
+		}
+		if !found {
+			m.Rcode = dns.RcodeNameError
+		}
+	default:
+		m.Rcode = dns.RcodeFormatError
+	}
+
+	return m
+}
+
+// Close shuts down the server.
+func (s *DNSServer) Close() {
+	close(s.done)
+	s.udp.Shutdown()
+	s.tcp.Shutdown()
+	if s.dot != nil {
+		s.dot.Shutdown()
+		s.doh.Close()
+	}
+}
@@ -0,0 +1,168 @@
+package pngtest
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// HTTPServer is a fake HTTP server. It also serves WebSocket when it is
+// started by NewWebSocketServer.
+type HTTPServer struct {
+	*Server
+
+	http   *http.Server
+	conns  *connListener
+	scheme string
+
+	mu     sync.Mutex
+	status int
+}
+
+// NewHTTPServer starts a fake HTTP server, which responds 200 OK to any
+// request.
+func NewHTTPServer() *HTTPServer {
+	return newHTTPServer("http", func(s *HTTPServer, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(s.getStatus())
+	})
+}
+
+// NewWebSocketServer starts a fake WebSocket server, which accepts a
+// WebSocket handshake and closes the connection.
+func NewWebSocketServer() *HTTPServer {
+	return newHTTPServer("ws", func(s *HTTPServer, w http.ResponseWriter, r *http.Request) {
+		if status := s.getStatus(); status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	})
+}
+
+func newHTTPServer(scheme string, handler func(*HTTPServer, http.ResponseWriter, *http.Request)) *HTTPServer {
+	s := &HTTPServer{
+		conns:  newConnListener(),
+		scheme: scheme,
+		status: http.StatusOK,
+	}
+	s.http = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if s.rejectsAuth() {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			handler(s, w, r)
+		}),
+	}
+
+	s.Server = newServer(func(addr string) string {
+		return scheme + "://" + addr
+	}, s.conns.serve, false)
+	s.run()
+	go s.http.Serve(s.conns)
+
+	return s
+}
+
+// SetStatus changes the status code of responses.
+func (s *HTTPServer) SetStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.status = status
+}
+
+// SetTLS makes the server serve HTTPS or WSS with config, or plain HTTP or WS
+// when config is nil. It also changes the scheme of URL.
+func (s *HTTPServer) SetTLS(config *tls.Config) {
+	s.Server.SetTLS(config)
+
+	if config != nil {
+		s.URL = s.scheme + "s://" + s.Addr
+	} else {
+		s.URL = s.scheme + "://" + s.Addr
+	}
+}
+
+func (s *HTTPServer) getStatus() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.status
+}
+
+// Close shuts down the server.
+func (s *HTTPServer) Close() {
+	s.http.Close()
+	s.Server.Close()
+}
+
+// connListener is a net.Listener to pass accepted connections to http.Server.
+type connListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener() *connListener {
+	return &connListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// serve passes conn to Accept, and waits for it to be closed.
+func (l *connListener) serve(conn net.Conn) {
+	c := &notifyConn{Conn: conn, closed: make(chan struct{})}
+
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		return
+	}
+
+	select {
+	case <-c.closed:
+	case <-l.done:
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, errors.New("pngtest: listener is closed")
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+type notifyConn struct {
+	net.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (c *notifyConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return c.Conn.Close()
+}
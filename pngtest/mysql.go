@@ -0,0 +1,293 @@
+package pngtest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+)
+
+const (
+	mysqlClientLongPassword     = 0x00000001
+	mysqlClientLongFlag         = 0x00000004
+	mysqlClientConnectWithDB    = 0x00000008
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientTransactions     = 0x00002000
+	mysqlClientSecureConn       = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+	mysqlClientPluginAuthLenEnc = 0x00200000
+
+	mysqlComQuit   = 0x01
+	mysqlComInitDB = 0x02
+	mysqlComQuery  = 0x03
+	mysqlComPing   = 0x0e
+)
+
+// MySQLServer is a fake MySQL server.
+//
+// It accepts User and Password with mysql_native_password, and responds to
+// COM_PING, COM_INIT_DB and COM_QUERY of the queries set by SetResult.
+type MySQLServer struct {
+	*Server
+}
+
+// NewMySQLServer starts a fake MySQL server.
+func NewMySQLServer() *MySQLServer {
+	s := &MySQLServer{}
+	s.Server = newServer(func(addr string) string {
+		return "mysql://" + User + ":" + Password + "@" + addr + "/png"
+	}, s.serve, true)
+	s.run()
+	return s
+}
+
+// mysqlConn reads and writes MySQL packets with sequence numbers.
+type mysqlConn struct {
+	r   *bufio.Reader
+	w   io.Writer
+	seq byte
+}
+
+func (c *mysqlConn) readPacket() ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	c.seq = header[3] + 1
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *mysqlConn) writePacket(data []byte) error {
+	size := len(data)
+	header := []byte{byte(size), byte(size >> 8), byte(size >> 16), c.seq}
+	c.seq++
+
+	_, err := c.w.Write(append(header, data...))
+	return err
+}
+
+func (c *mysqlConn) writeOK() error {
+	// header, affected rows, last insert ID, status flags, warnings
+	return c.writePacket([]byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+}
+
+func (c *mysqlConn) writeError(code uint16, state, message string) error {
+	data := []byte{0xff, byte(code), byte(code >> 8), '#'}
+	data = append(data, state...)
+	data = append(data, message...)
+	return c.writePacket(data)
+}
+
+func (c *mysqlConn) writeEOF() error {
+	// header, warnings, status flags
+	return c.writePacket([]byte{0xfe, 0x00, 0x00, 0x02, 0x00})
+}
+
+// writeResult writes result as a text resultset of VARCHAR columns.
+func (c *mysqlConn) writeResult(result *Result) error {
+	if err := c.writePacket(mysqlLenEncInt(nil, uint64(len(result.Columns)))); err != nil {
+		return err
+	}
+
+	for _, name := range result.Columns {
+		var data []byte
+		for _, s := range []string{"def", "", "", "", name, name} {
+			data = mysqlLenEncString(data, s)
+		}
+		// length of fixed fields, character set (utf8_general_ci), column
+		// length, type (VAR_STRING), flags, decimals and filler
+		data = append(data, 0x0c, 33, 0, 0xff, 0, 0, 0, 0xfd, 0, 0, 0, 0, 0)
+		if err := c.writePacket(data); err != nil {
+			return err
+		}
+	}
+	if err := c.writeEOF(); err != nil {
+		return err
+	}
+
+	for _, row := range result.Rows {
+		var data []byte
+		for i := range result.Columns {
+			if v, ok := result.value(row, i); ok {
+				data = mysqlLenEncString(data, v)
+			} else {
+				data = append(data, 0xfb) // NULL
+			}
+		}
+		if err := c.writePacket(data); err != nil {
+			return err
+		}
+	}
+	return c.writeEOF()
+}
+
+func mysqlLenEncInt(data []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(data, byte(n))
+	case n < 1<<16:
+		return append(data, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(data, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		data = append(data, 0xfe)
+		for i := uint(0); i < 64; i += 8 {
+			data = append(data, byte(n>>i))
+		}
+		return data
+	}
+}
+
+func mysqlLenEncString(data []byte, s string) []byte {
+	return append(mysqlLenEncInt(data, uint64(len(s))), s...)
+}
+
+func (s *MySQLServer) serve(conn net.Conn) {
+	c := &mysqlConn{r: bufio.NewReader(conn), w: conn}
+
+	tlsConfig := s.getTLSConfig()
+
+	scramble := []byte("0123456789abcdefghij")
+	if err := c.writePacket(mysqlHandshake(scramble, tlsConfig != nil)); err != nil {
+		return
+	}
+
+	data, err := c.readPacket()
+	if err != nil {
+		return
+	}
+
+	// SSLRequest is a HandshakeResponse41 cut after the reserved bytes.
+	if tlsConfig != nil && len(data) == 32 && binary.LittleEndian.Uint32(data)&mysqlClientSSL != 0 {
+		tlsConn := tls.Server(&bufferedConn{Conn: conn, r: c.r}, tlsConfig)
+		c.r = bufio.NewReader(tlsConn)
+		c.w = tlsConn
+
+		if data, err = c.readPacket(); err != nil {
+			return
+		}
+	}
+
+	user, authResp, ok := parseMySQLHandshakeResponse(data)
+	if !ok {
+		c.writeError(1043, "08S01", "Bad handshake")
+		return
+	}
+
+	if s.rejectsAuth() || user != User || !bytes.Equal(authResp, mysqlNativePassword(scramble, Password)) {
+		c.writeError(1045, "28000", "Access denied for user '"+user+"'@'localhost' (using password: YES)")
+		return
+	}
+
+	if err := c.writeOK(); err != nil {
+		return
+	}
+
+	for {
+		data, err := c.readPacket()
+		if err != nil || len(data) == 0 {
+			return
+		}
+
+		switch data[0] {
+		case mysqlComQuit:
+			return
+		case mysqlComPing, mysqlComInitDB:
+			err = c.writeOK()
+		case mysqlComQuery:
+			if result := s.result(string(data[1:])); result != nil {
+				err = c.writeResult(result)
+			} else {
+				err = c.writeError(1064, "42000", "You have an error in your SQL syntax")
+			}
+		default:
+			err = c.writeError(1047, "08S01", "Unknown command")
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+func mysqlHandshake(scramble []byte, ssl bool) []byte {
+	capabilities := uint32(mysqlClientLongPassword | mysqlClientLongFlag |
+		mysqlClientConnectWithDB | mysqlClientProtocol41 | mysqlClientTransactions |
+		mysqlClientSecureConn | mysqlClientPluginAuth)
+	if ssl {
+		capabilities |= mysqlClientSSL
+	}
+
+	var b bytes.Buffer
+	b.WriteByte(10) // protocol version
+	b.WriteString("5.7.0-pngtest\x00")
+	binary.Write(&b, binary.LittleEndian, uint32(1)) // connection ID
+	b.Write(scramble[:8])
+	b.WriteByte(0x00)
+	binary.Write(&b, binary.LittleEndian, uint16(capabilities))
+	b.WriteByte(33)                                  // utf8_general_ci
+	binary.Write(&b, binary.LittleEndian, uint16(2)) // SERVER_STATUS_AUTOCOMMIT
+	binary.Write(&b, binary.LittleEndian, uint16(capabilities>>16))
+	b.WriteByte(byte(len(scramble) + 1))
+	b.Write(make([]byte, 10))
+	b.Write(scramble[8:])
+	b.WriteByte(0x00)
+	b.WriteString("mysql_native_password\x00")
+	return b.Bytes()
+}
+
+// parseMySQLHandshakeResponse returns the user name and the auth response of
+// HandshakeResponse41 packet.
+func parseMySQLHandshakeResponse(data []byte) (user string, authResp []byte, ok bool) {
+	if len(data) < 32 {
+		return
+	}
+	flags := binary.LittleEndian.Uint32(data)
+	data = data[32:]
+
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return
+	}
+	user, data = string(data[:i]), data[i+1:]
+
+	// An auth response shorter than 251 bytes has a 1 byte length, either
+	// with CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA or CLIENT_SECURE_CONNECTION.
+	if flags&(mysqlClientPluginAuthLenEnc|mysqlClientSecureConn) == 0 || len(data) == 0 || data[0] >= 251 {
+		return
+	}
+	size := int(data[0])
+	if len(data) < 1+size {
+		return
+	}
+
+	return user, data[1 : 1+size], true
+}
+
+// mysqlNativePassword computes the auth response of mysql_native_password:
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password))).
+func mysqlNativePassword(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+
+	hash1 := sha1.Sum([]byte(password))
+	hash2 := sha1.Sum(hash1[:])
+	hash3 := sha1.Sum(append(append([]byte{}, scramble...), hash2[:]...))
+
+	for i := range hash3 {
+		hash3[i] ^= hash1[i]
+	}
+	return hash3[:]
+}
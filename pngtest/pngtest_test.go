@@ -0,0 +1,127 @@
+package pngtest_test
+
+import (
+	"testing"
+
+	"context"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/png"
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func ping(url string, timeout time.Duration) error {
+	p, err := png.Parse(url)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return p.Ping(ctx)
+}
+
+func TestServers(t *testing.T) {
+	for _, c := range []struct {
+		name  string
+		start func() (*pngtest.Server, func())
+		// auth is whether the server has authentication.
+		auth bool
+	}{
+		{"TCP", func() (*pngtest.Server, func()) {
+			s := pngtest.NewTCPServer()
+			return s, s.Close
+		}, false},
+		{"HTTP", func() (*pngtest.Server, func()) {
+			s := pngtest.NewHTTPServer()
+			return s.Server, s.Close
+		}, true},
+		{"WebSocket", func() (*pngtest.Server, func()) {
+			s := pngtest.NewWebSocketServer()
+			return s.Server, s.Close
+		}, true},
+		{"Redis", func() (*pngtest.Server, func()) {
+			s := pngtest.NewRedisServer()
+			return s.Server, s.Close
+		}, true},
+		{"MySQL", func() (*pngtest.Server, func()) {
+			s := pngtest.NewMySQLServer()
+			return s.Server, s.Close
+		}, true},
+		{"Postgres", func() (*pngtest.Server, func()) {
+			s := pngtest.NewPostgresServer()
+			return s.Server, s.Close
+		}, true},
+		{"AMQP", func() (*pngtest.Server, func()) {
+			s := pngtest.NewAMQPServer()
+			return s.Server, s.Close
+		}, true},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			t.Run("OK", func(t *testing.T) {
+				s, close := c.start()
+				defer close()
+
+				if err := ping(s.URL, time.Second); err != nil {
+					t.Fatalf("failed in p.Ping(): %+#v", err)
+				}
+			})
+
+			t.Run("Refuse", func(t *testing.T) {
+				s, close := c.start()
+				defer close()
+
+				s.SetRefuse(true)
+				if err := ping(s.URL, time.Second); err == nil {
+					t.Fatal("succeeded in p.Ping()")
+				} else if !strings.Contains(err.Error(), "connection refused") {
+					t.Fatalf("unexpected error message: %#v", err.Error())
+				}
+
+				s.SetRefuse(false)
+				if err := ping(s.URL, time.Second); err != nil {
+					t.Fatalf("failed in p.Ping(): %+#v", err)
+				}
+			})
+
+			if c.name == "TCP" {
+				return
+			}
+
+			t.Run("Latency", func(t *testing.T) {
+				s, close := c.start()
+				defer close()
+
+				s.SetLatency(200 * time.Millisecond)
+				if err := ping(s.URL, 100*time.Millisecond); err == nil {
+					t.Fatal("succeeded in p.Ping()")
+				}
+
+				if err := ping(s.URL, time.Second); err != nil {
+					t.Fatalf("failed in p.Ping(): %+#v", err)
+				}
+			})
+
+			t.Run("Malformed", func(t *testing.T) {
+				s, close := c.start()
+				defer close()
+
+				s.SetMalformed(true)
+				if err := ping(s.URL, time.Second); err == nil {
+					t.Fatal("succeeded in p.Ping()")
+				}
+			})
+
+			t.Run("RejectAuth", func(t *testing.T) {
+				s, close := c.start()
+				defer close()
+
+				s.SetRejectAuth(true)
+				if err := ping(s.URL, time.Second); err == nil {
+					t.Fatal("succeeded in p.Ping()")
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package pngtest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+const (
+	postgresProtocolVersion = 196608
+	postgresSSLRequest      = 80877103
+	postgresCancelRequest   = 80877102
+)
+
+// PostgresServer is a fake PostgreSQL server.
+//
+// It accepts User and Password with cleartext password authentication, and
+// responds to empty queries and the queries set by SetResult.
+type PostgresServer struct {
+	*Server
+}
+
+// NewPostgresServer starts a fake PostgreSQL server.
+func NewPostgresServer() *PostgresServer {
+	s := &PostgresServer{}
+	s.Server = newServer(func(addr string) string {
+		return "postgres://" + User + ":" + Password + "@" + addr + "/png"
+	}, s.serve, true)
+	s.run()
+	return s
+}
+
+// postgresConn reads and writes PostgreSQL messages.
+type postgresConn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// readStartup reads a startup packet, which has no type byte.
+func (c *postgresConn) readStartup() (code uint32, data []byte, err error) {
+	var header [8]byte
+	if _, err = io.ReadFull(c.r, header[:]); err != nil {
+		return
+	}
+
+	size := binary.BigEndian.Uint32(header[:4])
+	if size < 8 || size > 10000 {
+		err = errors.New("invalid startup packet")
+		return
+	}
+
+	code = binary.BigEndian.Uint32(header[4:])
+	data = make([]byte, size-8)
+	_, err = io.ReadFull(c.r, data)
+	return
+}
+
+func (c *postgresConn) readMessage() (typ byte, data []byte, err error) {
+	var header [5]byte
+	if _, err = io.ReadFull(c.r, header[:]); err != nil {
+		return
+	}
+
+	size := binary.BigEndian.Uint32(header[1:])
+	if size < 4 {
+		err = errors.New("invalid message")
+		return
+	}
+
+	typ = header[0]
+	data = make([]byte, size-4)
+	_, err = io.ReadFull(c.r, data)
+	return
+}
+
+func (c *postgresConn) writeMessage(typ byte, data []byte) {
+	var header [5]byte
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)+4))
+
+	c.w.Write(header[:])
+	c.w.Write(data)
+}
+
+func (c *postgresConn) writeInt32(typ byte, n uint32) {
+	var data [4]byte
+	binary.BigEndian.PutUint32(data[:], n)
+	c.writeMessage(typ, data[:])
+}
+
+func (c *postgresConn) writeError(severity, code, message string) {
+	var b bytes.Buffer
+	for _, field := range []struct {
+		typ   byte
+		value string
+	}{
+		{'S', severity},
+		{'V', severity},
+		{'C', code},
+		{'M', message},
+	} {
+		b.WriteByte(field.typ)
+		b.WriteString(field.value)
+		b.WriteByte(0)
+	}
+	b.WriteByte(0)
+
+	c.writeMessage('E', b.Bytes())
+}
+
+func (c *postgresConn) writeReady() {
+	c.writeMessage('Z', []byte{'I'})
+}
+
+func (s *PostgresServer) serve(conn net.Conn) {
+	c := &postgresConn{r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+
+	params, ok := s.startup(conn, c)
+	if !ok {
+		return
+	}
+
+	user := params["user"]
+	c.writeInt32('R', 3) // AuthenticationCleartextPassword
+	if err := c.w.Flush(); err != nil {
+		return
+	}
+
+	typ, data, err := c.readMessage()
+	if err != nil || typ != 'p' {
+		return
+	}
+
+	if !s.authenticate(user, string(bytes.TrimRight(data, "\x00"))) {
+		c.writeError("FATAL", "28P01", "password authentication failed for user \""+user+"\"")
+		c.w.Flush()
+		return
+	}
+
+	c.writeInt32('R', 0) // AuthenticationOk
+	for _, param := range [][2]string{
+		{"server_version", "10.0"},
+		{"server_encoding", "UTF8"},
+		{"client_encoding", "UTF8"},
+		{"DateStyle", "ISO, MDY"},
+		{"integer_datetimes", "on"},
+		{"standard_conforming_strings", "on"},
+	} {
+		c.writeMessage('S', []byte(param[0]+"\x00"+param[1]+"\x00"))
+	}
+	c.writeMessage('K', []byte{0, 0, 0, 1, 0, 0, 0, 1}) // BackendKeyData
+	c.writeReady()
+
+	for {
+		if err := c.w.Flush(); err != nil {
+			return
+		}
+
+		typ, data, err := c.readMessage()
+		if err != nil {
+			return
+		}
+
+		switch typ {
+		case 'X': // Terminate
+			return
+		case 'Q':
+			s.query(c, string(bytes.TrimRight(data, "\x00")))
+		default:
+			c.writeError("ERROR", "0A000", "unsupported message: "+string(typ))
+			c.writeReady()
+		}
+	}
+}
+
+// startup reads startup packets until the real one, and returns its
+// parameters.
+func (s *PostgresServer) startup(conn net.Conn, c *postgresConn) (map[string]string, bool) {
+	for {
+		code, data, err := c.readStartup()
+		if err != nil {
+			return nil, false
+		}
+
+		switch code {
+		case postgresSSLRequest:
+			tlsConfig := s.getTLSConfig()
+			if tlsConfig == nil {
+				if _, err := conn.Write([]byte{'N'}); err != nil {
+					return nil, false
+				}
+				continue
+			}
+
+			if _, err := conn.Write([]byte{'S'}); err != nil {
+				return nil, false
+			}
+
+			// Continues the startup over TLS.
+			tlsConn := tls.Server(&bufferedConn{Conn: conn, r: c.r}, tlsConfig)
+			c.r = bufio.NewReader(tlsConn)
+			c.w = bufio.NewWriter(tlsConn)
+			conn = tlsConn
+
+		case postgresCancelRequest:
+			return nil, false
+
+		case postgresProtocolVersion:
+			params := make(map[string]string)
+			fields := bytes.Split(bytes.TrimRight(data, "\x00"), []byte{0})
+			for i := 0; i+1 < len(fields); i += 2 {
+				params[string(fields[i])] = string(fields[i+1])
+			}
+			return params, true
+
+		default:
+			c.writeError("FATAL", "0A000", "unsupported frontend protocol")
+			c.w.Flush()
+			return nil, false
+		}
+	}
+}
+
+func (s *PostgresServer) query(c *postgresConn, query string) {
+	if result := s.result(query); result != nil {
+		c.writeResult(result)
+	} else if query = strings.TrimSpace(query); query == "" || query == ";" {
+		c.writeMessage('I', nil) // EmptyQueryResponse
+	} else {
+		c.writeError("ERROR", "42601", "syntax error")
+	}
+	c.writeReady()
+}
+
+// writeResult writes result as text columns.
+func (c *postgresConn) writeResult(result *Result) {
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, int16(len(result.Columns)))
+	for _, name := range result.Columns {
+		b.WriteString(name + "\x00")
+		// table OID, attribute number, type OID (text), type size, type
+		// modifier and format code
+		for _, v := range []interface{}{int32(0), int16(0), int32(25), int16(-1), int32(-1), int16(0)} {
+			binary.Write(&b, binary.BigEndian, v)
+		}
+	}
+	c.writeMessage('T', b.Bytes()) // RowDescription
+
+	for _, row := range result.Rows {
+		b.Reset()
+		binary.Write(&b, binary.BigEndian, int16(len(result.Columns)))
+		for i := range result.Columns {
+			v, ok := result.value(row, i)
+			if !ok {
+				binary.Write(&b, binary.BigEndian, int32(-1))
+				continue
+			}
+			binary.Write(&b, binary.BigEndian, int32(len(v)))
+			b.WriteString(v)
+		}
+		c.writeMessage('D', b.Bytes()) // DataRow
+	}
+
+	c.writeMessage('C', []byte(fmt.Sprintf("SELECT %d\x00", len(result.Rows)))) // CommandComplete
+}
@@ -0,0 +1,285 @@
+package pngtest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// RedisServer is a fake Redis server speaking RESP.
+//
+// It requires AUTH with Password, and accepts PING, ECHO, SELECT, INFO and
+// QUIT. INFO answers the replication and persistence sections of a master
+// without replicas by default, which is changed by SetInfo.
+type RedisServer struct {
+	*Server
+
+	// info is the fields of INFO, guarded by mu of Server.
+	info []redisInfoField
+
+	// noAuth is true when the server accepts commands without AUTH, like
+	// Redis Sentinel. It is guarded by mu of Server.
+	noAuth bool
+	// extra handles commands other than the common ones, and reports whether
+	// it handled the command.
+	extra func(w *bufio.Writer, name string, args []string) bool
+}
+
+// NewRedisServer starts a fake Redis server.
+func NewRedisServer() *RedisServer {
+	s := &RedisServer{}
+	s.Server = newServer(func(addr string) string {
+		return "redis://:" + Password + "@" + addr
+	}, s.serve, false)
+	s.run()
+	return s
+}
+
+type redisInfoField struct {
+	section, key, value string
+}
+
+func defaultRedisInfo() []redisInfoField {
+	return []redisInfoField{
+		{"replication", "role", "master"},
+		{"replication", "connected_slaves", "0"},
+		{"replication", "master_repl_offset", "0"},
+		{"persistence", "loading", "0"},
+		{"persistence", "rdb_last_bgsave_status", "ok"},
+		{"persistence", "aof_enabled", "0"},
+		{"persistence", "aof_last_bgrewrite_status", "ok"},
+		{"persistence", "aof_last_write_status", "ok"},
+	}
+}
+
+// SetInfo sets a field of INFO in section, like "replication" and
+// "persistence". The field is added when it does not exist.
+func (s *RedisServer) SetInfo(section, key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.info == nil {
+		s.info = defaultRedisInfo()
+	}
+
+	for i, f := range s.info {
+		if f.section == section && f.key == key {
+			s.info[i].value = value
+			return
+		}
+	}
+	s.info = append(s.info, redisInfoField{section, key, value})
+}
+
+func (s *RedisServer) infoString(section string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.info == nil {
+		s.info = defaultRedisInfo()
+	}
+
+	var b strings.Builder
+	current := ""
+	for _, f := range s.info {
+		if section != "" && f.section != section {
+			continue
+		}
+		if f.section != current {
+			current = f.section
+			fmt.Fprintf(&b, "# %s%s\r\n", strings.ToUpper(current[:1]), current[1:])
+		}
+		fmt.Fprintf(&b, "%s:%s\r\n", f.key, f.value)
+	}
+	return b.String()
+}
+
+func (s *RedisServer) acceptsNoAuth() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.noAuth
+}
+
+func (s *RedisServer) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+
+	authed := false
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToUpper(args[0])
+		args = args[1:]
+
+		switch {
+		case name == "QUIT":
+			writeRESP(w, "+OK")
+			w.Flush()
+			return
+
+		case name == "AUTH":
+			authed = s.auth(w, args)
+
+		case !authed && !s.acceptsNoAuth():
+			writeRESP(w, "-NOAUTH Authentication required.")
+
+		default:
+			s.command(w, name, args)
+		}
+
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// auth handles AUTH command, which takes a password, or a user name and a
+// password for ACL.
+func (s *RedisServer) auth(w *bufio.Writer, args []string) bool {
+	var ok bool
+	switch len(args) {
+	case 1:
+		ok = !s.rejectsAuth() && args[0] == Password
+	case 2:
+		ok = s.authenticate(args[0], args[1])
+	default:
+		writeRESP(w, "-ERR wrong number of arguments for 'auth' command")
+		return false
+	}
+
+	if !ok {
+		writeRESP(w, "-WRONGPASS invalid username-password pair or user is disabled.")
+		return false
+	}
+
+	writeRESP(w, "+OK")
+	return true
+}
+
+func (s *RedisServer) command(w *bufio.Writer, name string, args []string) {
+	switch name {
+	case "PING":
+		if len(args) == 0 {
+			writeRESP(w, "+PONG")
+		} else {
+			writeRESPBulk(w, args[0])
+		}
+
+	case "ECHO":
+		if len(args) != 1 {
+			writeRESP(w, "-ERR wrong number of arguments for 'echo' command")
+			return
+		}
+		writeRESPBulk(w, args[0])
+
+	case "INFO":
+		if len(args) > 1 {
+			writeRESP(w, "-ERR syntax error")
+			return
+		}
+
+		section := ""
+		if len(args) == 1 {
+			section = strings.ToLower(args[0])
+		}
+		writeRESPBulk(w, s.infoString(section))
+
+	case "SELECT":
+		if len(args) != 1 {
+			writeRESP(w, "-ERR wrong number of arguments for 'select' command")
+			return
+		}
+		if _, err := strconv.Atoi(args[0]); err != nil {
+			writeRESP(w, "-ERR invalid DB index")
+			return
+		}
+		writeRESP(w, "+OK")
+
+	default:
+		if s.extra == nil || !s.extra(w, name, args) {
+			writeRESP(w, fmt.Sprintf("-ERR unknown command '%s'", strings.ToLower(name)))
+		}
+	}
+}
+
+// readRESPCommand reads a command as an array of bulk strings, or as an
+// inline command.
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readRESPLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid array length: %q", line)
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		line, err := readRESPLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("invalid bulk string: %q", line)
+		}
+
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("invalid bulk string length: %q", line)
+		}
+
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+
+	return args, nil
+}
+
+func readRESPLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeRESP(w *bufio.Writer, line string) {
+	w.WriteString(line + "\r\n")
+}
+
+func writeRESPBulk(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+// writeRESPArray writes an array of bulk strings, or a null array when ss is
+// nil.
+func writeRESPArray(w *bufio.Writer, ss []string) {
+	if ss == nil {
+		writeRESP(w, "*-1")
+		return
+	}
+
+	fmt.Fprintf(w, "*%d\r\n", len(ss))
+	for _, s := range ss {
+		writeRESPBulk(w, s)
+	}
+}
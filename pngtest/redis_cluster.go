@@ -0,0 +1,203 @@
+package pngtest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MasterName is the name of the master monitored by RedisSentinelServer.
+const MasterName = "png"
+
+// RedisSentinelServer is a fake Redis Sentinel monitoring a master named
+// MasterName.
+//
+// It accepts SENTINEL get-master-addr-by-name and SENTINEL master without AUTH.
+type RedisSentinelServer struct {
+	*RedisServer
+
+	mu     sync.Mutex
+	master string
+	flags  string
+}
+
+// NewRedisSentinelServer starts a fake Redis Sentinel, which answers master as
+// the address of the master.
+func NewRedisSentinelServer(master string) *RedisSentinelServer {
+	s := &RedisSentinelServer{master: master, flags: "master"}
+	s.RedisServer = &RedisServer{noAuth: true, extra: s.command}
+	s.Server = newServer(func(addr string) string {
+		return "redis-sentinel://:" + Password + "@" + addr + "/" + MasterName
+	}, s.serve, false)
+	s.run()
+	return s
+}
+
+// SetMaster changes the address of the master.
+func (s *RedisSentinelServer) SetMaster(master string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.master = master
+}
+
+// SetRequireAuth makes the sentinel require AUTH with Password when require
+// is true.
+func (s *RedisSentinelServer) SetRequireAuth(require bool) {
+	s.Server.mu.Lock()
+	defer s.Server.mu.Unlock()
+
+	s.noAuth = !require
+}
+
+// SetMasterFlags changes the flags of the master, like "master,s_down" or
+// "master,o_down".
+func (s *RedisSentinelServer) SetMasterFlags(flags string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.flags = flags
+}
+
+func (s *RedisSentinelServer) command(w *bufio.Writer, name string, args []string) bool {
+	if name != "SENTINEL" {
+		return false
+	}
+
+	if len(args) != 2 {
+		writeRESP(w, "-ERR wrong number of arguments for 'sentinel' command")
+		return true
+	}
+
+	s.mu.Lock()
+	master, flags := s.master, s.flags
+	s.mu.Unlock()
+
+	host, port, _ := net.SplitHostPort(master)
+	known := args[1] == MasterName
+
+	switch strings.ToLower(args[0]) {
+	case "get-master-addr-by-name":
+		if !known {
+			writeRESPArray(w, nil)
+			return true
+		}
+		writeRESPArray(w, []string{host, port})
+
+	case "master":
+		if !known {
+			writeRESP(w, "-ERR No such master with that name")
+			return true
+		}
+		writeRESPArray(w, []string{
+			"name", MasterName,
+			"ip", host,
+			"port", port,
+			"flags", flags,
+			"num-slaves", "0",
+		})
+
+	default:
+		writeRESP(w, "-ERR Unknown sentinel subcommand '"+args[0]+"'")
+	}
+	return true
+}
+
+// RedisCluster is a fake Redis Cluster of masters.
+//
+// Each node accepts CLUSTER INFO and CLUSTER NODES, and slots are split
+// evenly among nodes.
+type RedisCluster struct {
+	// URL is the URL of this cluster for png.Parse, listing all nodes.
+	URL string
+	// Nodes are the nodes of this cluster.
+	Nodes []*RedisServer
+
+	mu    sync.Mutex
+	state string
+}
+
+// NewRedisCluster starts a fake Redis Cluster of n nodes.
+func NewRedisCluster(n int) *RedisCluster {
+	c := &RedisCluster{state: "ok"}
+
+	addrs := make([]string, n)
+	for i := 0; i < n; i++ {
+		i := i
+		node := &RedisServer{}
+		node.extra = func(w *bufio.Writer, name string, args []string) bool {
+			return c.command(w, i, name, args)
+		}
+		node.Server = newServer(func(addr string) string {
+			return "redis://:" + Password + "@" + addr
+		}, node.serve, false)
+		node.run()
+
+		c.Nodes = append(c.Nodes, node)
+		addrs[i] = node.Addr
+	}
+
+	c.URL = "redis-cluster://:" + Password + "@" + strings.Join(addrs, ",")
+	return c
+}
+
+// SetState changes cluster_state of CLUSTER INFO, like "fail".
+func (c *RedisCluster) SetState(state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.state = state
+}
+
+func (c *RedisCluster) command(w *bufio.Writer, self int, name string, args []string) bool {
+	if name != "CLUSTER" {
+		return false
+	}
+
+	if len(args) != 1 {
+		writeRESP(w, "-ERR wrong number of arguments for 'cluster' command")
+		return true
+	}
+
+	c.mu.Lock()
+	state := c.state
+	c.mu.Unlock()
+
+	switch strings.ToLower(args[0]) {
+	case "info":
+		writeRESPBulk(w, fmt.Sprintf(
+			"cluster_state:%s\r\ncluster_slots_assigned:16384\r\ncluster_known_nodes:%d\r\ncluster_size:%d\r\n",
+			state, len(c.Nodes), len(c.Nodes),
+		))
+
+	case "nodes":
+		var b strings.Builder
+		for i, node := range c.Nodes {
+			flags := "master"
+			if i == self {
+				flags = "myself,master"
+			}
+
+			// The cluster bus port is the port plus 10000.
+			_, port, _ := net.SplitHostPort(node.Addr)
+			cport, _ := strconv.Atoi(port)
+			from, to := 16384*i/len(c.Nodes), 16384*(i+1)/len(c.Nodes)-1
+			fmt.Fprintf(&b, "%040d %s@%d %s - 0 0 %d connected %d-%d\n", i+1, node.Addr, cport+10000, flags, i+1, from, to)
+		}
+		writeRESPBulk(w, b.String())
+
+	default:
+		writeRESP(w, "-ERR Unknown subcommand or wrong number of arguments for '"+args[0]+"'")
+	}
+	return true
+}
+
+// Close shuts down all nodes.
+func (c *RedisCluster) Close() {
+	for _, node := range c.Nodes {
+		node.Close()
+	}
+}
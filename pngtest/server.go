@@ -0,0 +1,290 @@
+// Package pngtest provides in-process fake servers for the targets png can ping.
+//
+// Each fake listens on a loopback port, and its URL can be passed to png.Parse
+// as is. The behavior of a fake can be changed while it is running, to
+// inject latency, refuse connections, reject authentication or return
+// malformed responses.
+package pngtest
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// User is the user name accepted by the fake servers.
+	User = "png"
+	// Password is the password accepted by the fake servers.
+	Password = "password"
+)
+
+// Server is the part common to all fake servers.
+type Server struct {
+	// URL is the URL of this server for png.Parse.
+	URL string
+	// Addr is the address this server listens on, like "127.0.0.1:12345".
+	Addr string
+
+	serve func(conn net.Conn)
+	// starttls is true when the protocol starts TLS by itself, like
+	// PostgreSQL and MySQL.
+	starttls bool
+
+	mu         sync.Mutex
+	listener   net.Listener
+	unix       []net.Listener
+	conns      map[net.Conn]struct{}
+	latency    time.Duration
+	rejectAuth bool
+	malformed  bool
+	tlsConfig  *tls.Config
+	results    map[string]*Result
+	closed     bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newServer listens on a loopback port, but does not accept connections
+// until run is called, so that the caller can set up the fields serve uses.
+// starttls is true when the protocol starts TLS by itself.
+func newServer(url func(addr string) string, serve func(conn net.Conn), starttls bool) *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to listen on a port: %v", err))
+	}
+
+	s := &Server{
+		Addr:     l.Addr().String(),
+		serve:    serve,
+		starttls: starttls,
+		conns:    make(map[net.Conn]struct{}),
+		done:     make(chan struct{}),
+	}
+	s.URL = url(s.Addr)
+	s.listener = l
+
+	return s
+}
+
+// run starts accepting connections.
+func (s *Server) run() {
+	s.accept(s.listener)
+}
+
+func (s *Server) start(l net.Listener) {
+	s.listener = l
+	s.accept(l)
+}
+
+func (s *Server) accept(l net.Listener) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			if !s.track(conn) {
+				conn.Close()
+				return
+			}
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.untrack(conn)
+				defer conn.Close()
+
+				s.handle(conn)
+			}()
+		}
+	}()
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	s.mu.Lock()
+	latency, malformed, tlsConfig := s.latency, s.malformed, s.tlsConfig
+	s.mu.Unlock()
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-s.done:
+			return
+		}
+	}
+
+	if malformed {
+		conn.Write([]byte("\x00malformed response\r\n"))
+		return
+	}
+
+	if tlsConfig != nil && !s.starttls {
+		conn = tls.Server(conn, tlsConfig)
+	}
+
+	s.serve(conn)
+}
+
+// bufferedConn is conn whose reads go through r, not to lose data read
+// ahead into r when a protocol switches to TLS.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// SetLatency delays the first response of every new connection by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = d
+}
+
+// SetRefuse closes the listener to refuse new connections when refuse is
+// true, and listens on the same address again when it is false.
+func (s *Server) SetRefuse(refuse bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || refuse == (s.listener == nil) {
+		return
+	}
+
+	if refuse {
+		s.listener.Close()
+		s.listener = nil
+		return
+	}
+
+	l, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		panic(fmt.Sprintf("pngtest: failed to listen on %s again: %v", s.Addr, err))
+	}
+	s.start(l)
+}
+
+// ListenUnix makes the server accept connections also on a unix socket at
+// path. The socket is not affected by SetRefuse.
+func (s *Server) ListenUnix(path string) error {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		l.Close()
+		return errors.New("pngtest: server is closed")
+	}
+
+	s.unix = append(s.unix, l)
+	s.accept(l)
+	return nil
+}
+
+// SetRejectAuth makes the server reject any credentials when reject is true.
+func (s *Server) SetRejectAuth(reject bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rejectAuth = reject
+}
+
+// SetMalformed makes the server respond garbage to new connections when
+// malformed is true.
+func (s *Server) SetMalformed(malformed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.malformed = malformed
+}
+
+// SetTLS makes the server speak TLS with config on new connections, or
+// plaintext when config is nil. PostgreSQL and MySQL fakes start TLS when the
+// client requests it, and the others start TLS on connect.
+func (s *Server) SetTLS(config *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tlsConfig = config
+}
+
+func (s *Server) getTLSConfig() *tls.Config {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.tlsConfig
+}
+
+func (s *Server) rejectsAuth() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.rejectAuth
+}
+
+// authenticate reports whether the given credentials are accepted.
+func (s *Server) authenticate(user, password string) bool {
+	return !s.rejectsAuth() && user == User && password == Password
+}
+
+// Close shuts down the server, and waits for all connections to be closed.
+func (s *Server) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.done)
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for _, l := range s.unix {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
@@ -0,0 +1,52 @@
+package pngtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Result is a result of a query, which the MySQL and PostgreSQL fakes
+// respond to the query set by SetResult.
+type Result struct {
+	Columns []string
+	// Rows are the values of rows. A value is a string, or nil for NULL.
+	Rows [][]interface{}
+}
+
+// SetResult makes the server respond result to query, or removes the result
+// of query when result is nil. Queries are compared without the spaces and
+// the semicolon around them.
+func (s *Server) SetResult(query string, result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.results == nil {
+		s.results = make(map[string]*Result)
+	}
+
+	query = normalizeQuery(query)
+	if result == nil {
+		delete(s.results, query)
+	} else {
+		s.results[query] = result
+	}
+}
+
+func (s *Server) result(query string) *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.results[normalizeQuery(query)]
+}
+
+func normalizeQuery(query string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+}
+
+// value returns the i-th value of row in text, and whether it is not NULL.
+func (r *Result) value(row []interface{}, i int) (string, bool) {
+	if i >= len(row) || row[i] == nil {
+		return "", false
+	}
+	return fmt.Sprint(row[i]), true
+}
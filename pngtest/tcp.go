@@ -0,0 +1,22 @@
+package pngtest
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+)
+
+// NewTCPServer starts a fake TCP server, which accepts connections and
+// discards anything sent to it.
+//
+// Latency has no effect on it, because a TCP connection is established
+// before it is accepted.
+func NewTCPServer() *Server {
+	s := newServer(func(addr string) string {
+		return "tcp://" + addr
+	}, func(conn net.Conn) {
+		io.Copy(ioutil.Discard, conn)
+	}, false)
+	s.run()
+	return s
+}
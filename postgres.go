@@ -2,22 +2,148 @@ package png
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net"
 	"net/url"
+	"sync"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
-
-	_ "github.com/lib/pq"
 )
 
 type PostgresPinger struct {
-	url *url.URL
+	url      *url.URL
+	password secret
+	tls      tlsOptions
+	query    queryCheck
+	checks   postgresChecks
+}
+
+func (p *PostgresPinger) String() string {
+	return displayURL(p.checks.display(p.query.display(p.url)), p.password, "5432")
 }
 
 func (p *PostgresPinger) Ping(ctx context.Context) error {
-	db, _ := sql.Open("postgres", p.url.String())
-	// sql.Open() must be succeeded when driver name is correct.
+	u, password, err := p.password.userURL(p.url)
+	if err != nil {
+		return errors.Wrap(err, "failed in Postgres ping")
+	}
+
+	if p.tls.set {
+		// TLS is started by postgresDialer instead of lib/pq, which cannot
+		// take a TLS config.
+		q := u.Query()
+		q.Set("sslmode", "disable")
+		v := *u
+		v.RawQuery = q.Encode()
+		u = &v
+	}
+
+	dialer := &postgresDialer{ctx: ctx, tls: p.tls}
+	defer dialer.close()
+
+	db := sql.OpenDB(&postgresConnector{dsn: u.String(), dialer: dialer})
 	defer db.Close()
 
-	return errors.Wrap(db.PingContext(ctx), "failed in Postgres ping")
+	if err := db.PingContext(ctx); err != nil {
+		return redact(errors.Wrap(err, "failed in Postgres ping"), password)
+	}
+
+	if err := p.checks.check(ctx, db); err != nil {
+		return redact(errors.Wrap(err, "failed in Postgres replication check"), password)
+	}
+
+	return redact(errors.Wrap(p.query.check(ctx, db), "failed in Postgres query"), password)
+}
+
+type postgresConnector struct {
+	dsn    string
+	dialer pq.Dialer
+}
+
+func (c *postgresConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return pq.DialOpen(c.dialer, c.dsn)
+}
+
+func (c *postgresConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// postgresDialer bounds connections by the context of a ping, because lib/pq
+// does not use a context on the startup of a connection.
+type postgresDialer struct {
+	ctx context.Context
+	tls tlsOptions
+
+	mu    sync.Mutex
+	stops []func()
+}
+
+func (d *postgresDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.dial(d.ctx, network, addr)
+}
+
+func (d *postgresDialer) DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(d.ctx, timeout)
+	defer cancel()
+
+	return d.dial(ctx, network, addr)
+}
+
+func (d *postgresDialer) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := dialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	d.mu.Lock()
+	d.stops = append(d.stops, closeOnDone(d.ctx, conn))
+	d.mu.Unlock()
+
+	if !d.tls.set {
+		return conn, nil
+	}
+
+	config, err := d.tls.config(d.ctx, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return postgresStartTLS(d.ctx, conn, config)
+}
+
+// postgresStartTLS requests the server to start TLS by SSLRequest, and
+// starts TLS on conn.
+func postgresStartTLS(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+	// length (8) and SSLRequest code (80877103)
+	if _, err := conn.Write([]byte{0, 0, 0, 8, 0x04, 0xd2, 0x16, 0x2f}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	var resp [1]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if resp[0] != 'S' {
+		conn.Close()
+		return nil, errors.New("server does not support TLS")
+	}
+
+	return tlsClient(ctx, conn, config)
+}
+
+func (d *postgresDialer) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, stop := range d.stops {
+		stop()
+	}
 }
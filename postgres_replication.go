@@ -0,0 +1,176 @@
+package png
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	postgresRoleQuery     = "SELECT pg_is_in_recovery()"
+	postgresStandbysQuery = "SELECT application_name, client_addr, state FROM pg_stat_replication"
+	// The lag is zero while a standby has replayed all WAL received, because
+	// the last replayed transaction gets older on an idle primary.
+	postgresReplayLagQuery = "SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 " +
+		"ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END"
+)
+
+// postgresChecks are the checks of the replication of a PostgreSQL server,
+// given by query parameters.
+type postgresChecks struct {
+	// role is "primary" or "standby", or "any" to report the role without
+	// check. The checks are disabled when it is empty.
+	role string
+	// minStandbys is the minimum number of streaming standbys of a primary.
+	minStandbys int
+	// maxReplayLag is the maximum replay lag of a standby, checked when
+	// checkLag is true.
+	maxReplayLag time.Duration
+	checkLag     bool
+}
+
+// parsePostgresChecks moves the replication parameters of u into
+// postgresChecks.
+func parsePostgresChecks(u *url.URL) (postgresChecks, error) {
+	var c postgresChecks
+
+	params := takeParams(u, "role", "min_standbys", "max_replay_lag")
+	if len(params) == 0 {
+		return c, nil
+	}
+
+	c.role = "any"
+	if v, ok := params["role"]; ok {
+		if v != "primary" && v != "standby" && v != "any" {
+			return c, errors.Errorf("invalid role: %#v (must be primary, standby or any)", v)
+		}
+		c.role = v
+	}
+
+	if v, ok := params["min_standbys"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return c, errors.Errorf("invalid min_standbys: %#v", v)
+		}
+		c.minStandbys = n
+	}
+
+	if v, ok := params["max_replay_lag"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return c, errors.Wrapf(err, "invalid max_replay_lag: %#v", v)
+		}
+		c.maxReplayLag, c.checkLag = d, true
+	}
+
+	return c, nil
+}
+
+// display returns u with the replication parameters of c.
+func (c postgresChecks) display(u *url.URL) *url.URL {
+	if c.role == "" {
+		return u
+	}
+
+	v := *u
+	q := v.Query()
+	q.Set("role", c.role)
+	if c.minStandbys > 0 {
+		q.Set("min_standbys", strconv.Itoa(c.minStandbys))
+	}
+	if c.checkLag {
+		q.Set("max_replay_lag", c.maxReplayLag.String())
+	}
+	v.RawQuery = q.Encode()
+	return &v
+}
+
+// check reports the role of the server on db, and the standbys of a primary
+// or the replay lag of a standby, and checks them by c.
+func (c postgresChecks) check(ctx context.Context, db *sql.DB) error {
+	if c.role == "" {
+		return nil
+	}
+
+	var recovery bool
+	if err := db.QueryRowContext(ctx, postgresRoleQuery).Scan(&recovery); err != nil {
+		return errors.Wrap(err, "failed in querying role")
+	}
+
+	role := "primary"
+	if recovery {
+		role = "standby"
+	}
+	report(ctx, "postgres_role", role)
+
+	if c.role != "any" && role != c.role {
+		return errors.Errorf("role is %#v (expected %#v)", role, c.role)
+	}
+
+	if recovery {
+		return c.checkStandby(ctx, db)
+	}
+	return c.checkPrimary(ctx, db)
+}
+
+func (c postgresChecks) checkPrimary(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, postgresStandbysQuery)
+	if err != nil {
+		return errors.Wrap(err, "failed in querying standbys")
+	}
+	defer rows.Close()
+
+	var standbys []string
+	streaming := 0
+	for rows.Next() {
+		var name, addr, state sql.NullString
+		if err := rows.Scan(&name, &addr, &state); err != nil {
+			return errors.Wrap(err, "failed in querying standbys")
+		}
+
+		standbys = append(standbys, fmt.Sprintf("%s (%s, %s)", name.String, addr.String, state.String))
+		if state.String == "streaming" {
+			streaming++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "failed in querying standbys")
+	}
+
+	report(ctx, "postgres_standbys", strings.Join(standbys, ", "))
+
+	if streaming < c.minStandbys {
+		return errors.Errorf("%d standbys streaming (less than %d)", streaming, c.minStandbys)
+	}
+	return nil
+}
+
+func (c postgresChecks) checkStandby(ctx context.Context, db *sql.DB) error {
+	var seconds sql.NullFloat64
+	if err := db.QueryRowContext(ctx, postgresReplayLagQuery).Scan(&seconds); err != nil {
+		return errors.Wrap(err, "failed in querying replay lag")
+	}
+
+	// The lag is NULL before the first transaction is replayed.
+	if !seconds.Valid {
+		report(ctx, "postgres_replay_lag", "unknown")
+		if c.checkLag {
+			return errors.New("replay lag is unknown")
+		}
+		return nil
+	}
+
+	lag := time.Duration(seconds.Float64 * float64(time.Second))
+	report(ctx, "postgres_replay_lag", lag.String())
+
+	if c.checkLag && lag > c.maxReplayLag {
+		return errors.Errorf("replay lag is %s (more than %s)", lag, c.maxReplayLag)
+	}
+	return nil
+}
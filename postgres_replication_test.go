@@ -0,0 +1,107 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestPostgresChecks(t *testing.T) {
+	ping := func(s *pngtest.PostgresServer, params string) (*Report, error) {
+		p, err := Parse(s.URL + params)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, r := WithReport(context.Background())
+		return r, p.Ping(ctx)
+	}
+
+	setRole := func(s *pngtest.PostgresServer, recovery string) {
+		s.SetResult(postgresRoleQuery, &pngtest.Result{
+			Columns: []string{"pg_is_in_recovery"},
+			Rows:    [][]interface{}{{recovery}},
+		})
+	}
+
+	t.Run("Primary", func(t *testing.T) {
+		s := pngtest.NewPostgresServer()
+		defer s.Close()
+
+		setRole(s, "f")
+		s.SetResult(postgresStandbysQuery, &pngtest.Result{
+			Columns: []string{"application_name", "client_addr", "state"},
+			Rows: [][]interface{}{
+				{"s1", "10.0.0.2", "streaming"},
+				{"s2", nil, "catchup"},
+			},
+		})
+
+		r, err := ping(s, "?role=primary&min_standbys=1")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		want := []Detail{{"postgres_role", "primary"}, {"postgres_standbys", "s1 (10.0.0.2, streaming), s2 (, catchup)"}}
+		if d := r.Details(); len(d) != 2 || d[0] != want[0] || d[1] != want[1] {
+			t.Fatalf("unexpected details: %+#v", d)
+		}
+
+		_, err = ping(s, "?min_standbys=2")
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "failed in Postgres replication check: 1 standbys streaming (less than 2)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		_, err = ping(s, "?role=standby")
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "failed in Postgres replication check: role is \"primary\" (expected \"standby\")" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Standby", func(t *testing.T) {
+		s := pngtest.NewPostgresServer()
+		defer s.Close()
+
+		setRole(s, "t")
+		s.SetResult(postgresReplayLagQuery, &pngtest.Result{
+			Columns: []string{"lag"},
+			Rows:    [][]interface{}{{"7.5"}},
+		})
+
+		r, err := ping(s, "?role=standby&max_replay_lag=10s")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		if d := r.Details(); len(d) != 2 || d[1] != (Detail{"postgres_replay_lag", "7.5s"}) {
+			t.Fatalf("unexpected details: %+#v", d)
+		}
+
+		_, err = ping(s, "?max_replay_lag=5s")
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "failed in Postgres replication check: replay lag is 7.5s (more than 5s)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Invalid Role", func(t *testing.T) {
+		p, err := Parse("postgres://localhost/db?role=master")
+		if err == nil {
+			t.Fatal("succeeded in Parse()", p)
+		}
+
+		if msg := err.Error(); msg != "invalid role: \"master\" (must be primary, standby or any)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
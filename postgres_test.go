@@ -9,7 +9,10 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path"
 	"strings"
+
+	"github.com/MakeNowJust/png/pngtest"
 )
 
 type PostgresServer struct {
@@ -114,3 +117,32 @@ func TestPostgresPinger(t *testing.T) {
 		}
 	})
 }
+
+func TestPostgresPingerUnixSocket(t *testing.T) {
+	s := pngtest.NewPostgresServer()
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "png")
+	if err != nil {
+		t.Fatalf("failed in creating a directory: %+#v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := s.ListenUnix(path.Join(dir, ".s.PGSQL.5432")); err != nil {
+		t.Skipf("unix socket is not available: %v", err)
+	}
+
+	for _, rawurl := range []string{
+		"postgres://" + pngtest.User + ":" + pngtest.Password + "@/png?host=" + url.QueryEscape(dir),
+		"postgresql://" + pngtest.User + ":" + pngtest.Password + "@" + url.PathEscape(dir) + "/png",
+	} {
+		p, err := Parse(rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping() of %s: %+#v", rawurl, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package png
+
+import (
+	"context"
+	"database/sql"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// queryCheck is a query run on each ping of a database, and the assertions
+// on the first value of its result. They are given by query parameters.
+type queryCheck struct {
+	query string
+
+	// expect is the expected value, checked when checkExpect is true.
+	expect      string
+	checkExpect bool
+
+	// max is the maximum number, checked when checkMax is true.
+	max      float64
+	checkMax bool
+}
+
+var queryParams = []string{"query", "query_expect", "query_max"}
+
+// parseQueryCheck moves the query parameters of u into queryCheck.
+func parseQueryCheck(u *url.URL) (queryCheck, error) {
+	var c queryCheck
+
+	params := takeParams(u, queryParams...)
+	if len(params) == 0 {
+		return c, nil
+	}
+
+	c.query = params["query"]
+	if c.query == "" {
+		return c, errors.New("query_expect and query_max need query")
+	}
+
+	c.expect, c.checkExpect = params["query_expect"]
+
+	if v, ok := params["query_max"]; ok {
+		max, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return c, errors.Wrapf(err, "invalid query_max: %#v", v)
+		}
+		c.max, c.checkMax = max, true
+	}
+
+	return c, nil
+}
+
+// takeParams removes the query parameters of names from u, and returns their
+// values by name.
+func takeParams(u *url.URL, names ...string) map[string]string {
+	q := u.Query()
+	params := make(map[string]string)
+	for _, name := range names {
+		if _, ok := q[name]; ok {
+			params[name] = q.Get(name)
+			q.Del(name)
+		}
+	}
+	if len(params) > 0 {
+		u.RawQuery = q.Encode()
+	}
+	return params
+}
+
+// display returns u with the query parameters of c.
+func (c queryCheck) display(u *url.URL) *url.URL {
+	if c.query == "" {
+		return u
+	}
+
+	v := *u
+	q := v.Query()
+	q.Set("query", c.query)
+	if c.checkExpect {
+		q.Set("query_expect", c.expect)
+	}
+	if c.checkMax {
+		q.Set("query_max", strconv.FormatFloat(c.max, 'g', -1, 64))
+	}
+	v.RawQuery = q.Encode()
+	return &v
+}
+
+// check runs the query on db, and checks the first value of its result. The
+// result must have a row at least.
+func (c queryCheck) check(ctx context.Context, db *sql.DB) error {
+	if c.query == "" {
+		return nil
+	}
+
+	rows, err := db.QueryContext(ctx, c.query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return errors.New("no row returned")
+	}
+
+	values := make([]interface{}, len(columns))
+	for i := range values {
+		values[i] = &sql.NullString{}
+	}
+	if err := rows.Scan(values...); err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return errors.New("no column returned")
+	}
+
+	value := values[0].(*sql.NullString)
+	if !value.Valid {
+		value.String = "NULL"
+	}
+	report(ctx, "query_result", value.String)
+
+	if c.checkExpect && value.String != c.expect {
+		return errors.Errorf("result is %#v (expected %#v)", value.String, c.expect)
+	}
+
+	if c.checkMax {
+		n, err := strconv.ParseFloat(value.String, 64)
+		if err != nil {
+			return errors.Errorf("result is not a number: %#v", value.String)
+		}
+		if n > c.max {
+			return errors.Errorf("result is %v (more than %v)", n, c.max)
+		}
+	}
+
+	return nil
+}
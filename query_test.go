@@ -0,0 +1,113 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"strings"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestQueryCheck(t *testing.T) {
+	t.Run("MySQL", func(t *testing.T) {
+		s := pngtest.NewMySQLServer()
+		defer s.Close()
+
+		testQueryCheck(t, s.Server, "failed in MySQL query: ")
+	})
+
+	t.Run("Postgres", func(t *testing.T) {
+		s := pngtest.NewPostgresServer()
+		defer s.Close()
+
+		testQueryCheck(t, s.Server, "failed in Postgres query: ")
+	})
+}
+
+// testQueryCheck runs the query checks on a fake database server s, whose
+// errors begin with prefix.
+func testQueryCheck(t *testing.T, s *pngtest.Server, prefix string) {
+	s.SetResult("SELECT lag", &pngtest.Result{
+		Columns: []string{"lag"},
+		Rows:    [][]interface{}{{"3"}},
+	})
+	s.SetResult("SELECT nothing", &pngtest.Result{Columns: []string{"x"}})
+
+	ping := func(params string) error {
+		p, err := Parse(s.URL + params)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+		return p.Ping(context.Background())
+	}
+
+	for _, params := range []string{
+		"?query=SELECT+lag",
+		"?query=SELECT+lag&query_expect=3",
+		"?query=SELECT+lag&query_max=3",
+	} {
+		if err := ping(params); err != nil {
+			t.Fatalf("failed in p.Ping() with %s: %+#v", params, err)
+		}
+	}
+
+	for _, e := range []struct {
+		params, msg string
+	}{
+		{"?query=SELECT+nothing", "no row returned"},
+		{"?query=SELECT+lag&query_expect=0", "result is \"3\" (expected \"0\")"},
+		{"?query=SELECT+lag&query_max=2.5", "result is 3 (more than 2.5)"},
+	} {
+		err := ping(e.params)
+		if err == nil {
+			t.Fatalf("succeeded to ping with %s", e.params)
+		}
+		if msg := err.Error(); msg != prefix+e.msg {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	}
+
+	err := ping("?query=SELECT+unknown")
+	if err == nil {
+		t.Fatal("succeeded to ping")
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, prefix) {
+		t.Fatalf("unexpected error message: %#v", msg)
+	}
+}
+
+func TestParseQueryCheck(t *testing.T) {
+	t.Run("Display", func(t *testing.T) {
+		p, err := Parse("postgres://localhost/db?query=SELECT+1&query_expect=1")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if s := Describe(p); s != "postgres://localhost:5432/db?query=SELECT+1&query_expect=1&sslmode=disable" {
+			t.Fatalf("unexpected display form: %#v", s)
+		}
+	})
+
+	t.Run("No Query", func(t *testing.T) {
+		p, err := Parse("mysql://localhost/db?query_max=1")
+		if err == nil {
+			t.Fatal("succeeded in Parse()", p)
+		}
+
+		if msg := err.Error(); msg != "query_expect and query_max need query" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Invalid Max", func(t *testing.T) {
+		p, err := Parse("mysql://localhost/db?query=SELECT+1&query_max=x")
+		if err == nil {
+			t.Fatal("succeeded in Parse()", p)
+		}
+
+		if msg := err.Error(); !strings.HasPrefix(msg, "invalid query_max: \"x\": ") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
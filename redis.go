@@ -2,6 +2,9 @@ package png
 
 import (
 	"context"
+	"net"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -9,16 +12,118 @@ import (
 )
 
 type RedisPinger struct {
+	scheme   string
 	addr     string
-	password string
+	username string
+	password secret
 	db       int
+	tls      tlsOptions
+	checks   redisChecks
+}
+
+// redisAuthError is an error of AUTH command, to tell it from errors of PING.
+type redisAuthError struct {
+	err error
+}
+
+func (e *redisAuthError) Error() string {
+	return e.err.Error()
+}
+
+func (p *RedisPinger) String() string {
+	scheme := p.scheme
+	if scheme == "" {
+		scheme = "redis"
+	}
+
+	u := &url.URL{Scheme: scheme, Host: p.addr}
+	if p.username != "" {
+		u.User = url.User(p.username)
+	}
+	if p.db != 0 {
+		u.Path = "/" + strconv.Itoa(p.db)
+	}
+	return displayURL(p.checks.display(u), p.password, "")
 }
 
 func (p *RedisPinger) Ping(ctx context.Context) error {
+	password, err := p.password.resolve()
+	if err != nil {
+		return errors.Wrap(err, "failed in AUTH command")
+	}
+
+	client := p.newClient(ctx, password)
+	defer client.Close()
+
+	result, err := redisDo(ctx, client, "ping")
+	if ctx.Err() != nil {
+		return errors.Wrap(ctx.Err(), "failed in PING command")
+	}
+
+	if e, ok := err.(*redisAuthError); ok {
+		return redact(errors.Wrap(e.err, "failed in AUTH command"), password)
+	}
+
+	if err != nil {
+		return redact(errors.Wrap(err, "failed in PING command"), password)
+	}
+
+	if result != "PONG" {
+		return errors.Errorf("invalid redis response: %#v", result)
+	}
+
+	return redact(p.checks.check(ctx, client, func(addr string) (map[string]string, error) {
+		return p.masterInfo(ctx, addr, password)
+	}), password)
+}
+
+// masterInfo returns the replication section of INFO of the master at addr,
+// connecting to it like p.
+func (p *RedisPinger) masterInfo(ctx context.Context, addr, password string) (map[string]string, error) {
+	master := *p
+	master.addr = addr
+	client := master.newClient(ctx, password)
+	defer client.Close()
+
+	info, err := redisInfoSection(ctx, client, "replication")
+	if e, ok := errors.Cause(err).(*redisAuthError); ok {
+		return nil, errors.Wrap(e.err, "failed in AUTH command")
+	}
+	return info, err
+}
+
+// newClient returns a client connecting to p.addr with a single connection,
+// which is bounded by ctx.
+func (p *RedisPinger) newClient(ctx context.Context, password string) *redis.Client {
 	opts := &redis.Options{
-		Addr:     p.addr,
-		Password: p.password,
-		DB:       p.db,
+		Addr: p.addr,
+		Dialer: func() (net.Conn, error) {
+			return p.tls.dial(ctx, "tcp", p.addr)
+		},
+		// AUTH and SELECT are sent on connect instead of by Password and DB,
+		// because go-redis does not support ACL users.
+		OnConnect: func(conn *redis.Conn) error {
+			if password != "" {
+				args := []interface{}{"auth"}
+				if p.username != "" {
+					args = append(args, p.username)
+				}
+				cmd := redis.NewStatusCmd(append(args, password)...)
+				conn.Process(cmd)
+				if err := cmd.Err(); err != nil {
+					return &redisAuthError{err: err}
+				}
+			}
+
+			if p.db != 0 {
+				return conn.Select(p.db).Err()
+			}
+			return nil
+		},
+		PoolSize: 1,
+		// Disables the idle connection reaper, whose goroutine would otherwise
+		// outlive the client for a minute.
+		IdleTimeout: -1,
 	}
 
 	if deadline, ok := ctx.Deadline(); ok {
@@ -28,29 +133,13 @@ func (p *RedisPinger) Ping(ctx context.Context) error {
 		opts.WriteTimeout = d
 	}
 
-	client := redis.NewClient(opts)
-	defer client.Close()
-
-	done := make(chan error)
-	go func() {
-		result, err := client.Ping().Result()
-		if err != nil {
-			done <- errors.Wrap(err, "failed in PING command")
-			return
-		}
-
-		if result != "PONG" {
-			done <- errors.Errorf("invalid redis response: %#v", result)
-			return
-		}
+	return redis.NewClient(opts)
+}
 
-		done <- nil
-	}()
+// redisDo runs a command on client, and interrupts it when ctx is done.
+func redisDo(ctx context.Context, client *redis.Client, args ...interface{}) (interface{}, error) {
+	stop := closeOnDone(ctx, client)
+	defer stop()
 
-	select {
-	case <-ctx.Done():
-		return errors.Wrap(ctx.Err(), "failed in PING command")
-	case err := <-done:
-		return err
-	}
+	return client.Do(args...).Result()
 }
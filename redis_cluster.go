@@ -0,0 +1,117 @@
+package png
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// RedisClusterPinger checks the state of a Redis Cluster, and pings every
+// master of the cluster.
+type RedisClusterPinger struct {
+	nodes []string
+	// node is the pinger of the nodes without the address.
+	node *RedisPinger
+}
+
+func (p *RedisClusterPinger) String() string {
+	u := &url.URL{Scheme: "redis-cluster", Host: strings.Join(p.nodes, ",")}
+	if p.node.username != "" {
+		u.User = url.User(p.node.username)
+	}
+	return displayURL(p.node.checks.display(u), p.node.password, "")
+}
+
+func (p *RedisClusterPinger) Ping(ctx context.Context) error {
+	password, err := p.node.password.resolve()
+	if err != nil {
+		return errors.Wrap(err, "failed in AUTH command")
+	}
+
+	// The given nodes are asked in order until one of them answers.
+	var failures []string
+	for _, addr := range p.nodes {
+		masters, err := p.askMasters(ctx, addr, password)
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "failed in CLUSTER command")
+		}
+
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", addr, redact(err, password)))
+			continue
+		}
+
+		report(ctx, "redis_node", addr)
+		report(ctx, "redis_masters", strings.Join(masters, ","))
+
+		for _, master := range masters {
+			node := *p.node
+			node.addr = master
+			if err := node.Ping(ctx); err != nil {
+				return errors.Wrapf(err, "failed in pinging master %s", master)
+			}
+		}
+		return nil
+	}
+
+	return errors.Errorf("failed in asking nodes for cluster: %s", strings.Join(failures, "; "))
+}
+
+// askMasters checks the cluster state in the view of addr, and returns the
+// addresses of the masters.
+func (p *RedisClusterPinger) askMasters(ctx context.Context, addr, password string) ([]string, error) {
+	node := *p.node
+	node.addr = addr
+	client := node.newClient(ctx, password)
+	defer client.Close()
+
+	result, err := redisDo(ctx, client, "cluster", "info")
+	if e, ok := err.(*redisAuthError); ok {
+		return nil, errors.Wrap(e.err, "failed in AUTH command")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, _ := result.(string)
+	state := redisInfo(info, ":")["cluster_state"]
+	report(ctx, "redis_cluster_state", state)
+	if state != "ok" {
+		return nil, errors.Errorf("cluster state is %#v", state)
+	}
+
+	result, err = redisDo(ctx, client, "cluster", "nodes")
+	if err != nil {
+		return nil, err
+	}
+
+	// Each line is `ID ADDR@CPORT FLAGS MASTER PING PONG EPOCH LINK SLOTS...`.
+	var masters []string
+	nodes, _ := result.(string)
+	for _, line := range strings.Split(strings.TrimSpace(nodes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		// A node without address is shown as `:0`, and is skipped.
+		addr := strings.SplitN(fields[1], "@", 2)[0]
+		if strings.HasPrefix(addr, ":") {
+			continue
+		}
+
+		for _, flag := range strings.Split(fields[2], ",") {
+			if flag == "master" {
+				masters = append(masters, addr)
+			}
+		}
+	}
+
+	if len(masters) == 0 {
+		return nil, errors.New("no master in cluster")
+	}
+	return masters, nil
+}
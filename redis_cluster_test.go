@@ -0,0 +1,75 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"strings"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestRedisClusterPingerPing(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		c := pngtest.NewRedisCluster(3)
+		defer c.Close()
+
+		p, err := Parse(c.URL)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, r := WithReport(context.Background())
+		if err := p.Ping(ctx); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		details := make(map[string]string)
+		for _, d := range r.Details() {
+			details[d.Name] = d.Value
+		}
+		if details["redis_cluster_state"] != "ok" || strings.Count(details["redis_masters"], ",") != 2 {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+	})
+
+	t.Run("Fail", func(t *testing.T) {
+		c := pngtest.NewRedisCluster(2)
+		defer c.Close()
+		c.SetState("fail")
+
+		p, err := Parse(c.URL)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); !strings.Contains(msg, "cluster state is \"fail\"") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Master Down", func(t *testing.T) {
+		c := pngtest.NewRedisCluster(2)
+		defer c.Close()
+
+		p, err := Parse(c.URL)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		c.Nodes[1].SetRefuse(true)
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); !strings.HasPrefix(msg, "failed in pinging master "+c.Nodes[1].Addr+": ") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package png
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+// redisChecks are the checks of a Redis node by INFO, given by query
+// parameters.
+type redisChecks struct {
+	// role is "master" or "replica", or empty for no check.
+	role string
+	// masterLink requires master_link_status:up of a replica.
+	masterLink bool
+	// maxLag is the maximum lag of the replication offset of replicas in
+	// bytes, checked when checkLag is true.
+	maxLag   int64
+	checkLag bool
+	// persistence requires the last RDB save and AOF write succeeded.
+	persistence bool
+}
+
+// parseRedisChecks moves the check parameters of u into redisChecks.
+func parseRedisChecks(u *url.URL) (redisChecks, error) {
+	var c redisChecks
+
+	q := u.Query()
+	defer func() {
+		u.RawQuery = q.Encode()
+	}()
+
+	if v, ok := q["role"]; ok {
+		switch v[0] {
+		case "master":
+			c.role = "master"
+		case "replica", "slave":
+			c.role = "replica"
+		default:
+			return c, errors.Errorf("invalid role: %#v (must be master or replica)", v[0])
+		}
+		q.Del("role")
+	}
+
+	for name, b := range map[string]*bool{"master_link": &c.masterLink, "persistence": &c.persistence} {
+		if v, ok := q[name]; ok {
+			ok, err := strconv.ParseBool(v[0])
+			if err != nil {
+				return c, errors.Wrapf(err, "invalid %s: %#v", name, v[0])
+			}
+			*b = ok
+			q.Del(name)
+		}
+	}
+
+	if v, ok := q["max_lag"]; ok {
+		n, err := strconv.ParseInt(v[0], 10, 64)
+		if err != nil || n < 0 {
+			return c, errors.Errorf("invalid max_lag: %#v", v[0])
+		}
+		c.maxLag, c.checkLag = n, true
+		q.Del("max_lag")
+	}
+
+	return c, nil
+}
+
+// display returns u with the check parameters of c.
+func (c redisChecks) display(u *url.URL) *url.URL {
+	v := *u
+	q := v.Query()
+	if c.role != "" {
+		q.Set("role", c.role)
+	}
+	if c.masterLink {
+		q.Set("master_link", "true")
+	}
+	if c.checkLag {
+		q.Set("max_lag", strconv.FormatInt(c.maxLag, 10))
+	}
+	if c.persistence {
+		q.Set("persistence", "true")
+	}
+	v.RawQuery = q.Encode()
+	return &v
+}
+
+// check runs INFO on client, and checks the node by c. masterInfo returns
+// the replication section of INFO of the master at addr, to check the lag of
+// a replica.
+func (c redisChecks) check(ctx context.Context, client *redis.Client, masterInfo func(addr string) (map[string]string, error)) error {
+	if c.role != "" || c.masterLink || c.checkLag {
+		info, err := redisInfoSection(ctx, client, "replication")
+		if err != nil {
+			return err
+		}
+		if err := c.checkReplication(ctx, info, masterInfo); err != nil {
+			return err
+		}
+	}
+
+	if c.persistence {
+		info, err := redisInfoSection(ctx, client, "persistence")
+		if err != nil {
+			return err
+		}
+		if err := c.checkPersistence(ctx, info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c redisChecks) checkReplication(ctx context.Context, info map[string]string, masterInfo func(addr string) (map[string]string, error)) error {
+	role := info["role"]
+	if role == "slave" {
+		role = "replica"
+	}
+	report(ctx, "redis_role", role)
+
+	if c.role != "" && role != c.role {
+		return errors.Errorf("role is %#v (expected %#v)", role, c.role)
+	}
+
+	if c.masterLink {
+		if role != "replica" {
+			return errors.Errorf("no master link on %s", role)
+		}
+
+		status := info["master_link_status"]
+		report(ctx, "redis_master_link", status)
+		if status != "up" {
+			return errors.Errorf("master link is %#v", status)
+		}
+	}
+
+	if c.checkLag && role == "replica" {
+		return c.checkReplicaLag(ctx, info, masterInfo)
+	}
+
+	if c.checkLag {
+		offset, _ := strconv.ParseInt(info["master_repl_offset"], 10, 64)
+
+		// Replicas are listed as `slaveN:ip=IP,port=PORT,state=STATE,offset=N,lag=S`.
+		n, _ := strconv.Atoi(info["connected_slaves"])
+		for i := 0; i < n; i++ {
+			replica := redisInfo(strings.Replace(info[fmt.Sprintf("slave%d", i)], ",", "\n", -1), "=")
+			addr := replica["ip"] + ":" + replica["port"]
+
+			replicaOffset, _ := strconv.ParseInt(replica["offset"], 10, 64)
+			lag := offset - replicaOffset
+			report(ctx, "redis_lag", fmt.Sprintf("%s %d", addr, lag))
+
+			if lag > c.maxLag {
+				return errors.Errorf("replica %s lags %d bytes (more than %d bytes)", addr, lag, c.maxLag)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkReplicaLag checks the lag of a replica itself, between the offset of
+// its master and the offset processed by it. The offset of the master is read
+// from the master, because master_repl_offset of a replica is its own offset.
+func (c redisChecks) checkReplicaLag(ctx context.Context, info map[string]string, masterInfo func(addr string) (map[string]string, error)) error {
+	if status := info["master_link_status"]; status != "up" {
+		return errors.Errorf("lag is unknown (master link is %#v)", status)
+	}
+
+	replicaOffset, err := strconv.ParseInt(info["slave_repl_offset"], 10, 64)
+	if err != nil {
+		return errors.New("lag is unknown (no replication offset in INFO)")
+	}
+
+	addr := net.JoinHostPort(info["master_host"], info["master_port"])
+	master, err := masterInfo(addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed in asking master %s for lag", addr)
+	}
+	offset, err := strconv.ParseInt(master["master_repl_offset"], 10, 64)
+	if err != nil {
+		return errors.Errorf("lag is unknown (no replication offset in INFO of master %s)", addr)
+	}
+
+	lag := offset - replicaOffset
+	report(ctx, "redis_lag", fmt.Sprintf("%s %d", addr, lag))
+
+	if lag > c.maxLag {
+		return errors.Errorf("replica lags %d bytes behind master %s (more than %d bytes)", lag, addr, c.maxLag)
+	}
+	return nil
+}
+
+func (c redisChecks) checkPersistence(ctx context.Context, info map[string]string) error {
+	if status, ok := info["rdb_last_bgsave_status"]; ok {
+		report(ctx, "redis_rdb_status", status)
+		if status != "ok" {
+			return errors.Errorf("last RDB save failed: %#v", status)
+		}
+	}
+
+	if info["aof_enabled"] == "1" {
+		status := info["aof_last_write_status"]
+		report(ctx, "redis_aof_status", status)
+		if status != "ok" {
+			return errors.Errorf("last AOF write failed: %#v", status)
+		}
+	}
+
+	return nil
+}
+
+// redisInfoSection runs INFO of section, and parses it.
+func redisInfoSection(ctx context.Context, client *redis.Client, section string) (map[string]string, error) {
+	result, err := redisDo(ctx, client, "info", section)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed in INFO command")
+	}
+
+	info, ok := result.(string)
+	if !ok {
+		return nil, errors.Errorf("invalid redis response: %#v", result)
+	}
+	return redisInfo(info, ":"), nil
+}
+
+// redisInfo parses lines of `key:value` of INFO and CLUSTER INFO, or of
+// another separator.
+func redisInfo(info, sep string) map[string]string {
+	m := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, sep); i >= 0 && !strings.HasPrefix(line, "#") {
+			m[line[:i]] = line[i+len(sep):]
+		}
+	}
+	return m
+}
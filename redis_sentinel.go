@@ -0,0 +1,141 @@
+package png
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+)
+
+// RedisSentinelPinger asks Redis Sentinels for the master of a group, and
+// pings the master.
+type RedisSentinelPinger struct {
+	sentinels []string
+	master    string
+	// password is the password of the sentinels, not of the master.
+	password secret
+	// node is the pinger of the master without the address.
+	node *RedisPinger
+}
+
+func (p *RedisSentinelPinger) String() string {
+	u := &url.URL{Scheme: "redis-sentinel", Host: strings.Join(p.sentinels, ","), Path: "/" + p.master}
+	if p.node.username != "" {
+		u.User = url.User(p.node.username)
+	}
+	if p.node.db != 0 {
+		u.Path += fmt.Sprintf("/%d", p.node.db)
+	}
+	u = p.node.checks.display(u)
+	if p.password.isSet() {
+		q := u.Query()
+		q.Set("sentinel_password", "xxxxx")
+		u.RawQuery = q.Encode()
+	}
+	return displayURL(u, p.node.password, "")
+}
+
+func (p *RedisSentinelPinger) Ping(ctx context.Context) error {
+	// Sentinels are asked in order until one of them answers.
+	var failures []string
+	for _, sentinel := range p.sentinels {
+		addr, err := p.askMaster(ctx, sentinel)
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "failed in SENTINEL command")
+		}
+
+		// The master is down in the agreement of sentinels, so the others
+		// are not asked.
+		if e, ok := err.(*redisMasterDownError); ok {
+			return e
+		}
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", sentinel, err))
+			continue
+		}
+
+		report(ctx, "redis_sentinel", sentinel)
+		report(ctx, "redis_master", addr)
+
+		node := *p.node
+		node.addr = addr
+		return errors.Wrapf(node.Ping(ctx), "failed in pinging master %s", addr)
+	}
+
+	return errors.Errorf("failed in asking sentinels for master %#v: %s", p.master, strings.Join(failures, "; "))
+}
+
+// askMaster returns the address of the master, and checks the master is not
+// down in the view of sentinel.
+func (p *RedisSentinelPinger) askMaster(ctx context.Context, sentinel string) (string, error) {
+	password, err := p.password.resolve()
+	if err != nil {
+		return "", err
+	}
+
+	client := (&RedisPinger{addr: sentinel, tls: p.node.tls}).newClient(ctx, password)
+	defer client.Close()
+
+	result, err := redisDo(ctx, client, "sentinel", "get-master-addr-by-name", p.master)
+	if err == redis.Nil {
+		return "", errors.Errorf("unknown master %#v", p.master)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	addr, ok := result.([]interface{})
+	if !ok || len(addr) != 2 {
+		return "", errors.Errorf("invalid sentinel response: %#v", result)
+	}
+	host, _ := addr[0].(string)
+	port, _ := addr[1].(string)
+
+	result, err = redisDo(ctx, client, "sentinel", "master", p.master)
+	if err != nil {
+		return "", err
+	}
+
+	info := redisMap(result)
+	report(ctx, "redis_master_flags", info["flags"])
+	report(ctx, "redis_replicas", info["num-slaves"])
+
+	for _, flag := range strings.Split(info["flags"], ",") {
+		switch flag {
+		case "o_down":
+			return "", &redisMasterDownError{master: p.master, sentinel: sentinel}
+		case "s_down":
+			warn(ctx, "master %#v is subjectively down on %s", p.master, sentinel)
+		}
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// redisMasterDownError is the error of a master objectively down.
+type redisMasterDownError struct {
+	master   string
+	sentinel string
+}
+
+func (e *redisMasterDownError) Error() string {
+	return fmt.Sprintf("master %#v is down on %s", e.master, e.sentinel)
+}
+
+// redisMap converts a flat array of keys and values to a map.
+func redisMap(result interface{}) map[string]string {
+	m := make(map[string]string)
+
+	values, _ := result.([]interface{})
+	for i := 0; i+1 < len(values); i += 2 {
+		key, _ := values[i].(string)
+		value, _ := values[i+1].(string)
+		m[key] = value
+	}
+
+	return m
+}
@@ -0,0 +1,182 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"strings"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestRedisSentinelPingerPing(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		master := pngtest.NewRedisServer()
+		defer master.Close()
+		s := pngtest.NewRedisSentinelServer(master.Addr)
+		defer s.Close()
+
+		p, err := Parse(s.URL)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, r := WithReport(context.Background())
+		if err := p.Ping(ctx); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		found := false
+		for _, d := range r.Details() {
+			if d.Name == "redis_master" && d.Value == master.Addr {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("no master in details: %+#v", r.Details())
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		master := pngtest.NewRedisServer()
+		defer master.Close()
+		down := pngtest.NewRedisSentinelServer(master.Addr)
+		down.SetRefuse(true)
+		defer down.Close()
+		s := pngtest.NewRedisSentinelServer(master.Addr)
+		defer s.Close()
+
+		p, err := Parse("redis-sentinel://:" + pngtest.Password + "@" + down.Addr + "," + s.Addr + "/" + pngtest.MasterName)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+	})
+
+	t.Run("Unknown Master", func(t *testing.T) {
+		s := pngtest.NewRedisSentinelServer("127.0.0.1:6379")
+		defer s.Close()
+
+		p, err := Parse("redis-sentinel://" + s.Addr + "/unknown")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); msg != "failed in asking sentinels for master \"unknown\": "+s.Addr+": unknown master \"unknown\"" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Down", func(t *testing.T) {
+		master := pngtest.NewRedisServer()
+		defer master.Close()
+		s := pngtest.NewRedisSentinelServer(master.Addr)
+		defer s.Close()
+
+		p, err := Parse(s.URL)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		s.SetMasterFlags("master,s_down")
+		ctx, r := WithReport(context.Background())
+		if err := p.Ping(ctx); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+		if w := r.Warnings(); len(w) != 1 || !strings.Contains(w[0], "subjectively down") {
+			t.Fatalf("unexpected warnings: %#v", w)
+		}
+
+		s.SetMasterFlags("master,s_down,o_down")
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "master \"png\" is down on "+s.Addr {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Down Without Fallback", func(t *testing.T) {
+		master := pngtest.NewRedisServer()
+		defer master.Close()
+		down := pngtest.NewRedisSentinelServer(master.Addr)
+		down.SetMasterFlags("master,s_down,o_down")
+		defer down.Close()
+		s := pngtest.NewRedisSentinelServer(master.Addr)
+		defer s.Close()
+
+		p, err := Parse("redis-sentinel://:" + pngtest.Password + "@" + down.Addr + "," + s.Addr + "/" + pngtest.MasterName)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "master \"png\" is down on "+down.Addr {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Sentinel Password", func(t *testing.T) {
+		master := pngtest.NewRedisServer()
+		defer master.Close()
+		s := pngtest.NewRedisSentinelServer(master.Addr)
+		defer s.Close()
+		s.SetRequireAuth(true)
+
+		p, err := Parse(s.URL)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); !strings.Contains(msg, "NOAUTH") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		p, err = Parse(s.URL + "?sentinel_password=" + pngtest.Password)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+	})
+
+	t.Run("Master Down", func(t *testing.T) {
+		master := pngtest.NewRedisServer()
+		defer master.Close()
+		master.SetRejectAuth(true)
+		s := pngtest.NewRedisSentinelServer(master.Addr)
+		defer s.Close()
+
+		p, err := Parse(s.URL)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); !strings.HasPrefix(msg, "failed in pinging master "+master.Addr+": failed in AUTH command: ") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/MakeNowJust/png/pngtest"
 	"github.com/alicebob/miniredis"
 )
 
@@ -37,13 +38,205 @@ func TestRedisPingerPing(t *testing.T) {
 
 		s.RequireAuth("password")
 
-		p := &RedisPinger{addr: addr, password: "password"}
+		p := &RedisPinger{addr: addr, password: secret{raw: "password"}}
 		err := p.Ping(context.Background())
 		if err != nil {
 			t.Fatalf("failed in p.Ping(): %+#v", err)
 		}
 	})
 
+	t.Run("ACL User", func(t *testing.T) {
+		s := pngtest.NewRedisServer()
+		defer s.Close()
+
+		p, err := Parse("redis://" + pngtest.User + ":" + pngtest.Password + "@" + s.Addr + "/1")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+	})
+
+	t.Run("Wrong Password", func(t *testing.T) {
+		s := pngtest.NewRedisServer()
+		defer s.Close()
+
+		p := &RedisPinger{addr: s.Addr, username: "png", password: secret{raw: "wrong"}}
+		err := p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); msg != "failed in AUTH command: WRONGPASS invalid username-password pair or user is disabled." {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("TLS", func(t *testing.T) {
+		ca := pngtest.NewCA()
+		s := pngtest.NewRedisServer()
+		defer s.Close()
+		s.SetTLS(ca.ServerConfig(ca.Issue("127.0.0.1"), false))
+
+		p, err := Parse("rediss://:" + pngtest.Password + "@" + s.Addr + "?tls_insecure=true")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		if d := Describe(p); d != "rediss://:xxxxx@"+s.Addr {
+			t.Fatalf("unexpected display form: %#v", d)
+		}
+	})
+
+	t.Run("Replication", func(t *testing.T) {
+		s := pngtest.NewRedisServer()
+		defer s.Close()
+
+		s.SetInfo("replication", "connected_slaves", "1")
+		s.SetInfo("replication", "slave0", "ip=10.0.0.2,port=6379,state=online,offset=900,lag=0")
+		s.SetInfo("replication", "master_repl_offset", "1000")
+
+		p, err := Parse(s.URL + "?role=master&max_lag=100")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		s.SetInfo("replication", "master_repl_offset", "1200")
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "replica 10.0.0.2:6379 lags 300 bytes (more than 100 bytes)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Replica", func(t *testing.T) {
+		s := pngtest.NewRedisServer()
+		defer s.Close()
+
+		p, err := Parse(s.URL + "?role=replica&master_link=true")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "role is \"master\" (expected \"replica\")" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		s.SetInfo("replication", "role", "slave")
+		s.SetInfo("replication", "master_link_status", "down")
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "master link is \"down\"" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		s.SetInfo("replication", "master_link_status", "up")
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+	})
+
+	t.Run("Replica Lag", func(t *testing.T) {
+		master := pngtest.NewRedisServer()
+		defer master.Close()
+		master.SetInfo("replication", "master_repl_offset", "1000")
+
+		host, port, err := net.SplitHostPort(master.Addr)
+		if err != nil {
+			panic(err)
+		}
+
+		// A replica reports its own offset as both master_repl_offset and
+		// slave_repl_offset.
+		s := pngtest.NewRedisServer()
+		defer s.Close()
+		s.SetInfo("replication", "role", "slave")
+		s.SetInfo("replication", "master_host", host)
+		s.SetInfo("replication", "master_port", port)
+		s.SetInfo("replication", "master_link_status", "up")
+		s.SetInfo("replication", "slave_repl_offset", "950")
+		s.SetInfo("replication", "master_repl_offset", "950")
+
+		p, err := Parse(s.URL + "?max_lag=100")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		s.SetInfo("replication", "slave_repl_offset", "800")
+		s.SetInfo("replication", "master_repl_offset", "800")
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "replica lags 200 bytes behind master "+master.Addr+" (more than 100 bytes)" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		master.Close()
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); !strings.HasPrefix(msg, "failed in asking master "+master.Addr+" for lag: ") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		s.SetInfo("replication", "master_link_status", "down")
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "lag is unknown (master link is \"down\")" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Persistence", func(t *testing.T) {
+		s := pngtest.NewRedisServer()
+		defer s.Close()
+
+		p, err := Parse(s.URL + "?persistence=true")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if err := p.Ping(context.Background()); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		s.SetInfo("persistence", "aof_enabled", "1")
+		s.SetInfo("persistence", "aof_last_write_status", "err")
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+		if msg := err.Error(); msg != "last AOF write failed: \"err\"" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
 	t.Run("Cancel", func(t *testing.T) {
 		s, addr := runMiniredis()
 		defer s.Close()
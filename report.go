@@ -0,0 +1,76 @@
+package png
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Detail is a piece of information found by a ping, like the expiry of a TLS
+// certificate.
+type Detail struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Report collects details and warnings of a ping. It is given to pingers by
+// a context of WithReport.
+type Report struct {
+	mu       sync.Mutex
+	details  []Detail
+	warnings []string
+}
+
+type reportKey struct{}
+
+// WithReport returns a context, which makes pingers put details and warnings
+// into the returned Report.
+func WithReport(ctx context.Context) (context.Context, *Report) {
+	r := &Report{}
+	return context.WithValue(ctx, reportKey{}, r), r
+}
+
+// Details returns the details reported so far. A nil Report has none.
+func (r *Report) Details() []Detail {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Detail(nil), r.details...)
+}
+
+// Warnings returns the warnings reported so far. A warning is a problem not
+// failing a ping.
+func (r *Report) Warnings() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]string(nil), r.warnings...)
+}
+
+// report puts a detail into the Report of ctx if any.
+func report(ctx context.Context, name, value string) {
+	if r, ok := ctx.Value(reportKey{}).(*Report); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.details = append(r.details, Detail{Name: name, Value: value})
+	}
+}
+
+// warn puts a warning into the Report of ctx if any.
+func warn(ctx context.Context, format string, args ...interface{}) {
+	if r, ok := ctx.Value(reportKey{}).(*Report); ok {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		r.warnings = append(r.warnings, fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,109 @@
+package png
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RetryPolicy decides how RetryPinger retries a failing ping.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts. Zero means unlimited,
+	// then the deadline of the context limits attempts.
+	MaxAttempts int
+
+	// InitialInterval is the wait after the first failure (default 100ms).
+	InitialInterval time.Duration
+	// MaxInterval is the upper bound of the wait (default 10s).
+	MaxInterval time.Duration
+	// Multiplier grows the wait after each failure (default 2).
+	Multiplier float64
+	// Jitter randomizes each wait by up to this fraction of it, e.g. 0.2 for ±20%.
+	Jitter float64
+
+	// Clock is used to wait. SystemClock is used when it is nil.
+	Clock Clock
+}
+
+// RetryPinger retries a failing ping with exponential backoff.
+type RetryPinger struct {
+	pinger Pinger
+	policy RetryPolicy
+}
+
+// WithRetry wraps p to retry a failing ping by policy.
+func WithRetry(p Pinger, policy RetryPolicy) *RetryPinger {
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = 100 * time.Millisecond
+	}
+	if policy.MaxInterval <= 0 {
+		policy.MaxInterval = 10 * time.Second
+	}
+	if policy.Multiplier < 1 {
+		policy.Multiplier = 2
+	}
+	if policy.Clock == nil {
+		policy.Clock = SystemClock
+	}
+
+	return &RetryPinger{pinger: p, policy: policy}
+}
+
+func (p *RetryPinger) String() string {
+	return Describe(p.pinger)
+}
+
+func (p *RetryPinger) Ping(ctx context.Context) error {
+	_, err := p.PingAttempts(ctx)
+	return err
+}
+
+// PingAttempts pings until it succeeds, and returns the number of attempts.
+//
+// It gives up when the attempts reach MaxAttempts, or when the next attempt
+// would start after the deadline of ctx.
+func (p *RetryPinger) PingAttempts(ctx context.Context) (int, error) {
+	clock := p.policy.Clock
+	interval := p.policy.InitialInterval
+
+	for attempt := 1; ; attempt++ {
+		err := p.pinger.Ping(ctx)
+		if err == nil {
+			return attempt, nil
+		}
+
+		if ctx.Err() != nil || attempt == p.policy.MaxAttempts {
+			return attempt, retryError(err, attempt)
+		}
+
+		wait := p.jitter(interval)
+		if deadline, ok := ctx.Deadline(); ok && clock.Now().Add(wait).After(deadline) {
+			return attempt, retryError(err, attempt)
+		}
+
+		if clock.Sleep(ctx, wait) != nil {
+			return attempt, retryError(err, attempt)
+		}
+
+		interval = time.Duration(float64(interval) * p.policy.Multiplier)
+		if interval > p.policy.MaxInterval {
+			interval = p.policy.MaxInterval
+		}
+	}
+}
+
+func (p *RetryPinger) jitter(d time.Duration) time.Duration {
+	if p.policy.Jitter <= 0 {
+		return d
+	}
+	return time.Duration(float64(d) * (1 + p.policy.Jitter*(2*rand.Float64()-1)))
+}
+
+func retryError(err error, attempts int) error {
+	if attempts == 1 {
+		return errors.Wrap(err, "failed in 1 attempt")
+	}
+	return errors.Wrapf(err, "failed in %d attempts", attempts)
+}
@@ -0,0 +1,119 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"errors"
+	"time"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+func TestRetryPinger(t *testing.T) {
+	refused := errors.New("connection refused")
+
+	t.Run("OK", func(t *testing.T) {
+		clock := pngtest.NewFakeClock()
+		p := WithRetry(NewFakePinger(clock,
+			FakeError(0, refused),
+			FakeError(0, refused),
+			FakeOK(10*time.Millisecond),
+		), RetryPolicy{Clock: clock})
+
+		start := clock.Now()
+		attempts, err := p.PingAttempts(context.Background())
+		if err != nil {
+			t.Fatalf("failed in p.PingAttempts(): %+#v", err)
+		}
+
+		if attempts != 3 {
+			t.Fatalf("unexpected attempts: %d", attempts)
+		}
+
+		if elapsed := clock.Now().Sub(start); elapsed != 310*time.Millisecond {
+			t.Fatalf("unexpected elapsed time: %v", elapsed)
+		}
+	})
+
+	t.Run("Max Attempts", func(t *testing.T) {
+		clock := pngtest.NewFakeClock()
+		p := WithRetry(NewFakePinger(clock, FakeError(0, refused)), RetryPolicy{
+			MaxAttempts: 4,
+			MaxInterval: 300 * time.Millisecond,
+			Clock:       clock,
+		})
+
+		start := clock.Now()
+		attempts, err := p.PingAttempts(context.Background())
+		if err == nil {
+			t.Fatal("succeeded in p.PingAttempts()")
+		}
+
+		if msg := err.Error(); msg != "failed in 4 attempts: connection refused" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		if attempts != 4 {
+			t.Fatalf("unexpected attempts: %d", attempts)
+		}
+
+		// 100ms + 200ms + 300ms (capped by MaxInterval)
+		if elapsed := clock.Now().Sub(start); elapsed != 600*time.Millisecond {
+			t.Fatalf("unexpected elapsed time: %v", elapsed)
+		}
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		clock := pngtest.NewFakeClock()
+		p := WithRetry(NewFakePinger(clock, FakeError(0, refused)), RetryPolicy{Clock: clock})
+
+		ctx, cancel := TimeoutContext(context.Background(), clock, 500*time.Millisecond)
+		defer cancel()
+
+		attempts, err := p.PingAttempts(ctx)
+		if err == nil {
+			t.Fatal("succeeded in p.PingAttempts()")
+		}
+
+		// Attempts at 0ms, 100ms and 300ms. The next one would be at 700ms.
+		if attempts != 3 {
+			t.Fatalf("unexpected attempts: %d", attempts)
+		}
+
+		if ctx.Err() != nil {
+			t.Fatalf("unexpected context error: %+#v", ctx.Err())
+		}
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		clock := pngtest.NewFakeClock()
+		p := WithRetry(NewFakePinger(clock, FakeTimeout()), RetryPolicy{Clock: clock})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		attempts, err := p.PingAttempts(ctx)
+		if err == nil {
+			t.Fatal("succeeded in p.PingAttempts()")
+		}
+
+		if msg := err.Error(); msg != "failed in 1 attempt: context canceled" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+
+		if attempts != 1 {
+			t.Fatalf("unexpected attempts: %d", attempts)
+		}
+	})
+
+	t.Run("Jitter", func(t *testing.T) {
+		p := WithRetry(nil, RetryPolicy{Jitter: 0.2})
+
+		for i := 0; i < 100; i++ {
+			if d := p.jitter(time.Second); d < 800*time.Millisecond || d > 1200*time.Millisecond {
+				t.Fatalf("unexpected jittered interval: %v", d)
+			}
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package png
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// secret is the password of a target. It is kept apart from the URL, and is
+// resolved on each ping, so that the password is not held by a pinger.
+type secret struct {
+	// raw is the password given in the URL, which may refer environment
+	// variables as `${NAME}`.
+	raw string
+	// file is the path of a file containing the password, given by the
+	// `password_file` query parameter.
+	file string
+}
+
+// parseSecret moves the password and the `password_file` query parameter of
+// u into a secret.
+func parseSecret(u *url.URL) (secret, error) {
+	var s secret
+
+	if user := u.User; user != nil {
+		if password, ok := user.Password(); ok && password != "" {
+			s.raw = password
+			u.User = url.User(user.Username())
+		}
+	}
+
+	if q := u.Query(); q.Get("password_file") != "" {
+		if s.raw != "" {
+			return s, errors.New("both password and password_file are given")
+		}
+
+		s.file = q.Get("password_file")
+		q.Del("password_file")
+		u.RawQuery = q.Encode()
+	}
+
+	return s, nil
+}
+
+func (s secret) isSet() bool {
+	return s.raw != "" || s.file != ""
+}
+
+// resolve returns the password, reading the file or environment variables.
+func (s secret) resolve() (string, error) {
+	if s.file != "" {
+		b, err := ioutil.ReadFile(s.file)
+		if err != nil {
+			return "", errors.Wrap(err, "failed in reading password file")
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	return expandEnv(s.raw)
+}
+
+// userURL returns a copy of u with the resolved password.
+func (s secret) userURL(u *url.URL) (*url.URL, string, error) {
+	if !s.isSet() {
+		return u, "", nil
+	}
+
+	password, err := s.resolve()
+	if err != nil {
+		return nil, "", err
+	}
+
+	v := *u
+	username := ""
+	if u.User != nil {
+		username = u.User.Username()
+	}
+	v.User = url.UserPassword(username, password)
+	return &v, password, nil
+}
+
+// expandEnv replaces `${NAME}` in s with the environment variable NAME.
+// Unlike os.ExpandEnv, `$NAME` is left as it is, because passwords may
+// contain `$`.
+func expandEnv(s string) (string, error) {
+	var expanded []byte
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+
+		j := strings.Index(s[i:], "}")
+		if j < 0 {
+			break
+		}
+
+		name := s[i+2 : i+j]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", errors.Errorf("undefined environment variable: %s", name)
+		}
+
+		expanded = append(expanded, s[:i]...)
+		expanded = append(expanded, value...)
+		s = s[i+j+1:]
+	}
+
+	return string(append(expanded, s...)), nil
+}
+
+// redact hides the resolved password in the message of err, in case that a
+// driver puts a URL or a DSN with it into the message. The cause of err is
+// kept.
+func redact(err error, password string) error {
+	if err == nil || password == "" {
+		return err
+	}
+
+	msg := redactMessage(err.Error(), password)
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, msg: msg}
+}
+
+// redactMessage hides password in the userinfo of URLs and DSNs in msg, and
+// in `password=...` of key-value DSNs. The other occurrences are left, not to
+// mangle the message with a short password.
+func redactMessage(msg, password string) string {
+	escaped := strings.TrimPrefix(url.UserPassword("", password).String(), ":")
+	quoted := regexp.QuoteMeta(password) + "|" + regexp.QuoteMeta(escaped)
+	param := regexp.MustCompile(`password=(?:` + quoted + `)([\s'"&;]|$)`)
+
+	for _, p := range []string{password, escaped} {
+		msg = strings.Replace(msg, ":"+p+"@", ":xxxxx@", -1)
+	}
+	return param.ReplaceAllString(msg, "password=xxxxx$1")
+}
+
+// redactedError is err whose message is redacted by redact.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Cause() error {
+	return e.err
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// splitUserinfo splits rawurl into the part before userinfo, userinfo and
+// the rest beginning with `@`. userinfo is empty if rawurl has no userinfo.
+func splitUserinfo(rawurl string) (head, userinfo, tail string) {
+	i := strings.Index(rawurl, "://")
+	if i < 0 {
+		return rawurl, "", ""
+	}
+	i += len("://")
+
+	authority := rawurl[i:]
+	if j := strings.IndexAny(authority, "/?#"); j >= 0 {
+		authority = authority[:j]
+	}
+
+	j := strings.LastIndex(authority, "@")
+	if j < 0 {
+		return rawurl, "", ""
+	}
+
+	return rawurl[:i], authority[:j], rawurl[i+j:]
+}
+
+// escapeUserinfo escapes braces of `${NAME}` in userinfo of rawurl, which
+// url.Parse rejects.
+func escapeUserinfo(rawurl string) string {
+	head, userinfo, tail := splitUserinfo(rawurl)
+	userinfo = strings.Replace(userinfo, "{", "%7B", -1)
+	userinfo = strings.Replace(userinfo, "}", "%7D", -1)
+	return head + userinfo + tail
+}
+
+// redactURL hides the password in rawurl.
+func redactURL(rawurl string) string {
+	head, userinfo, tail := splitUserinfo(rawurl)
+	if i := strings.Index(userinfo, ":"); i >= 0 {
+		userinfo = userinfo[:i] + ":xxxxx"
+	}
+	return head + userinfo + tail
+}
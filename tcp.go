@@ -12,6 +12,10 @@ type TCPPinger struct {
 	addr    string
 }
 
+func (p *TCPPinger) String() string {
+	return p.network + "://" + p.addr
+}
+
 func (p *TCPPinger) Ping(ctx context.Context) error {
 	dialer := &net.Dialer{}
 	conn, err := dialer.DialContext(ctx, p.network, p.addr)
@@ -0,0 +1,339 @@
+package png
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// TLSPinger checks the TLS handshake and the certificates of a server.
+type TLSPinger struct {
+	addr string
+	tls  tlsOptions
+}
+
+func (p *TLSPinger) String() string {
+	return "tls://" + p.addr
+}
+
+func (p *TLSPinger) Ping(ctx context.Context) error {
+	o := p.tls
+	o.set = true
+	o.report = true
+
+	conn, err := o.dial(ctx, "tcp", p.addr)
+	if err != nil {
+		return errors.Wrap(err, "failed in TLS ping")
+	}
+	conn.Close()
+
+	return nil
+}
+
+// tlsParams are the query parameters configuring TLS. They are removed from
+// the URL of a target, and enable TLS of the target.
+var tlsParams = []string{
+	"tls_ca",
+	"tls_cert",
+	"tls_key",
+	"tls_server_name",
+	"tls_min_version",
+	"tls_insecure",
+}
+
+// tlsCheckParams are the query parameters of the checks of certificates. They
+// are removed from the URL of a target, but do not enable TLS.
+var tlsCheckParams = []string{
+	"tls_report",
+	"tls_min_days",
+	"tls_warn_days",
+}
+
+// noTLSSchemes are the schemes never using TLS, whose targets reject TLS
+// parameters instead of ignoring them.
+var noTLSSchemes = map[string]bool{
+	"tcp":   true,
+	"tcp4":  true,
+	"tcp6":  true,
+	"udp":   true,
+	"udp4":  true,
+	"udp6":  true,
+	"icmp":  true,
+	"icmp6": true,
+	"dns":   true,
+}
+
+// rejectTLSParams returns an error when u has any TLS parameter.
+func rejectTLSParams(u *url.URL) error {
+	q := u.Query()
+	for _, names := range [][]string{tlsParams, tlsCheckParams} {
+		for _, name := range names {
+			if _, ok := q[name]; ok {
+				return errors.Errorf("%s cannot be used with %s URL (no TLS)", name, u.Scheme)
+			}
+		}
+	}
+	return nil
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsOptions is TLS configuration of a target. Files are read on each ping,
+// like secret.
+type tlsOptions struct {
+	// set is true when any TLS parameter is given. It enables TLS for the
+	// schemes which are not always over TLS.
+	set bool
+
+	ca         string
+	cert       string
+	key        string
+	serverName string
+	minVersion uint16
+	insecure   bool
+
+	// report makes connections report the certificates and the parameters
+	// of TLS.
+	report bool
+	// minDays and warnDays are the days before the expiry of certificates
+	// to fail and to warn. Zero means no check.
+	minDays  int
+	warnDays int
+
+	// clock is used to check the expiry. SystemClock is used when it is nil.
+	clock Clock
+}
+
+// parseTLSOptions moves the TLS parameters of u into tlsOptions. defaults
+// give the values of parameters missing in u, but do not enable TLS.
+func parseTLSOptions(u *url.URL, defaults url.Values) (tlsOptions, error) {
+	var o tlsOptions
+
+	// Only the parameters of the target enable TLS, and the defaults are
+	// used by targets already using TLS.
+	params := takeParams(u, tlsParams...)
+	o.set = len(params) > 0
+	for name, value := range takeParams(u, tlsCheckParams...) {
+		params[name] = value
+	}
+	for _, names := range [][]string{tlsParams, tlsCheckParams} {
+		for _, name := range names {
+			if _, ok := params[name]; ok {
+				continue
+			}
+			if _, ok := defaults[name]; ok {
+				params[name] = defaults.Get(name)
+			}
+		}
+	}
+
+	if len(params) == 0 {
+		return o, nil
+	}
+
+	o.ca = params["tls_ca"]
+	o.cert = params["tls_cert"]
+	o.key = params["tls_key"]
+	o.serverName = params["tls_server_name"]
+
+	if (o.cert == "") != (o.key == "") {
+		return o, errors.New("tls_cert and tls_key must be given together")
+	}
+
+	if v, ok := params["tls_min_version"]; ok {
+		if o.minVersion, ok = tlsVersions[v]; !ok {
+			return o, errors.Errorf("invalid tls_min_version: %#v (must be 1.0, 1.1, 1.2 or 1.3)", v)
+		}
+	}
+
+	for name, b := range map[string]*bool{"tls_insecure": &o.insecure, "tls_report": &o.report} {
+		if v, ok := params[name]; ok {
+			ok, err := strconv.ParseBool(v)
+			if err != nil {
+				return o, errors.Wrapf(err, "invalid %s: %#v", name, v)
+			}
+			*b = ok
+		}
+	}
+
+	for name, days := range map[string]*int{"tls_min_days": &o.minDays, "tls_warn_days": &o.warnDays} {
+		if v, ok := params[name]; ok {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return o, errors.Errorf("invalid %s: %#v", name, v)
+			}
+			*days = n
+		}
+	}
+
+	return o, nil
+}
+
+// config returns the TLS config to connect to addr. The server name is left
+// empty when addr is empty, for clients which fill it per connection.
+//
+// The connection is inspected by the config when the certificates are
+// reported or checked, so that every client does it.
+func (o tlsOptions) config(ctx context.Context, addr string) (*tls.Config, error) {
+	c := &tls.Config{
+		ServerName:         o.serverName,
+		MinVersion:         o.minVersion,
+		InsecureSkipVerify: o.insecure,
+	}
+
+	if o.clock != nil {
+		c.Time = o.clock.Now
+	}
+
+	if o.report || o.minDays > 0 || o.warnDays > 0 {
+		c.VerifyConnection = func(state tls.ConnectionState) error {
+			return o.inspect(ctx, state)
+		}
+	}
+
+	if c.ServerName == "" && addr != "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			host = addr
+		}
+		c.ServerName = host
+	}
+
+	if o.ca != "" {
+		pem, err := ioutil.ReadFile(o.ca)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed in reading tls_ca")
+		}
+
+		c.RootCAs = x509.NewCertPool()
+		if !c.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificate in tls_ca: %s", o.ca)
+		}
+	}
+
+	if o.cert != "" {
+		cert, err := tls.LoadX509KeyPair(o.cert, o.key)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed in loading tls_cert and tls_key")
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	return c, nil
+}
+
+// dial is dialContext over TLS when TLS is enabled by o.
+func (o tlsOptions) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := dialContext(ctx, network, addr)
+	if err != nil || !o.set {
+		return conn, err
+	}
+
+	config, err := o.config(ctx, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsClient(ctx, conn, config)
+}
+
+// inspect reports the certificates and the parameters of a TLS connection
+// when o.report is true, and checks the expiry of the certificates.
+func (o tlsOptions) inspect(ctx context.Context, state tls.ConnectionState) error {
+	if o.report {
+		report(ctx, "tls_version", tlsVersionName(state.Version))
+		report(ctx, "tls_cipher", tls.CipherSuiteName(state.CipherSuite))
+	}
+
+	certs := state.PeerCertificates
+	if len(certs) == 0 {
+		return nil
+	}
+
+	leaf := certs[0]
+	if o.report {
+		report(ctx, "tls_subject", leaf.Subject.String())
+		report(ctx, "tls_sans", strings.Join(certSANs(leaf), ", "))
+		report(ctx, "tls_issuer", leaf.Issuer.String())
+	}
+
+	// The chain expires when any of its certificates expires.
+	expiring := leaf
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(expiring.NotAfter) {
+			expiring = cert
+		}
+	}
+
+	clock := o.clock
+	if clock == nil {
+		clock = SystemClock
+	}
+
+	days := int(math.Floor(expiring.NotAfter.Sub(clock.Now()).Hours() / 24))
+	if o.report {
+		report(ctx, "tls_expiry_days", strconv.Itoa(days))
+	}
+
+	if o.minDays > 0 && days < o.minDays {
+		return errors.Errorf("certificate %#v expires in %d days (less than %d days)", expiring.Subject.CommonName, days, o.minDays)
+	}
+	if o.warnDays > 0 && days < o.warnDays {
+		warn(ctx, "certificate %#v expires in %d days (less than %d days)", expiring.Subject.CommonName, days, o.warnDays)
+	}
+
+	return nil
+}
+
+func tlsVersionName(version uint16) string {
+	for name, v := range tlsVersions {
+		if v == version {
+			return "TLS " + name
+		}
+	}
+	return fmt.Sprintf("0x%04x", version)
+}
+
+// certSANs returns the subject alternative names of cert.
+func certSANs(cert *x509.Certificate) []string {
+	sans := append([]string(nil), cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return sans
+}
+
+// tlsClient starts TLS on conn. The handshake is bounded by ctx, even if ctx
+// has no deadline.
+func tlsClient(ctx context.Context, conn net.Conn, config *tls.Config) (net.Conn, error) {
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, errors.Wrap(err, "failed in TLS handshake")
+	}
+	return tlsConn, nil
+}
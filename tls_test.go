@@ -0,0 +1,359 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MakeNowJust/png/pngtest"
+)
+
+type tlsServer interface {
+	SetTLS(config *tls.Config)
+	Close()
+}
+
+func TestTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "png")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := pngtest.NewCA()
+	server := ca.Issue("127.0.0.1")
+	named := ca.Issue("png.test")
+	client := ca.Issue("png")
+
+	files := map[string][]byte{
+		"ca.pem":   ca.CertPEM,
+		"cert.pem": client.CertPEM,
+		"key.pem":  client.KeyPEM,
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			panic(err)
+		}
+	}
+
+	withParams := func(rawurl string, params ...string) string {
+		q := url.Values{}
+		for i := 0; i+1 < len(params); i += 2 {
+			q.Set(params[i], params[i+1])
+		}
+
+		if strings.Contains(rawurl, "?") {
+			return rawurl + "&" + q.Encode()
+		}
+		return rawurl + "?" + q.Encode()
+	}
+
+	caFile := filepath.Join(dir, "ca.pem")
+	for _, c := range []struct {
+		name  string
+		start func() (tlsServer, func() string)
+	}{
+		{"HTTPS", func() (tlsServer, func() string) {
+			s := pngtest.NewHTTPServer()
+			return s, func() string { return s.URL }
+		}},
+		{"WSS", func() (tlsServer, func() string) {
+			s := pngtest.NewWebSocketServer()
+			return s, func() string { return s.URL }
+		}},
+		{"MySQL", func() (tlsServer, func() string) {
+			s := pngtest.NewMySQLServer()
+			return s, func() string { return s.URL }
+		}},
+		{"Postgres", func() (tlsServer, func() string) {
+			s := pngtest.NewPostgresServer()
+			return s, func() string { return s.URL }
+		}},
+		{"Redis", func() (tlsServer, func() string) {
+			s := pngtest.NewRedisServer()
+			return s, func() string { return s.URL }
+		}},
+		{"AMQP", func() (tlsServer, func() string) {
+			s := pngtest.NewAMQPServer()
+			return s, func() string { return s.URL }
+		}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			s, serverURL := c.start()
+			defer s.Close()
+
+			ping := func(rawurl string) error {
+				p, err := Parse(rawurl)
+				if err != nil {
+					t.Fatalf("failed in Parse(): %+#v", err)
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				return p.Ping(ctx)
+			}
+
+			s.SetTLS(ca.ServerConfig(server, false))
+
+			if err := ping(withParams(serverURL(), "tls_ca", caFile)); err != nil {
+				t.Fatalf("failed in p.Ping() with tls_ca: %+#v", err)
+			}
+
+			if err := ping(withParams(serverURL(), "tls_min_version", "1.2")); err == nil {
+				t.Fatal("succeeded in p.Ping() with unknown CA")
+			}
+
+			if err := ping(withParams(serverURL(), "tls_insecure", "true")); err != nil {
+				t.Fatalf("failed in p.Ping() with tls_insecure: %+#v", err)
+			}
+
+			s.SetTLS(ca.ServerConfig(named, false))
+
+			if err := ping(withParams(serverURL(), "tls_ca", caFile, "tls_server_name", "png.test")); err != nil {
+				t.Fatalf("failed in p.Ping() with tls_server_name: %+#v", err)
+			}
+
+			s.SetTLS(ca.ServerConfig(server, true))
+
+			if err := ping(withParams(serverURL(), "tls_ca", caFile, "tls_cert", filepath.Join(dir, "cert.pem"), "tls_key", filepath.Join(dir, "key.pem"))); err != nil {
+				t.Fatalf("failed in p.Ping() with tls_cert: %+#v", err)
+			}
+
+			if err := ping(withParams(serverURL(), "tls_ca", caFile)); err == nil {
+				t.Fatal("succeeded in p.Ping() without client certificate")
+			}
+		})
+	}
+}
+
+func TestTLSCancel(t *testing.T) {
+	// A server never responding to handshakes.
+	s, addr := runTCPServer("tcp", "127.0.0.1:0", func(conn net.Conn) {
+		ioutil.ReadAll(conn)
+	})
+	defer s.Close()
+
+	for _, rawurl := range []string{
+		"tls://" + addr,
+		"dot://" + addr + "/example.com",
+		"rediss://" + addr,
+		"amqps://" + addr,
+		"postgres://" + addr + "?tls_insecure=true",
+	} {
+		p, err := Parse(rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(%#v): %+#v", rawurl, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errc := make(chan error, 1)
+		go func() {
+			errc <- p.Ping(ctx)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+
+		select {
+		case err := <-errc:
+			if err == nil {
+				t.Fatalf("succeeded to ping %s", rawurl)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("canceled ping of %s did not return", rawurl)
+		}
+	}
+}
+
+func TestParseTLS(t *testing.T) {
+	t.Run("Params", func(t *testing.T) {
+		p, err := ParseWithDefaults("postgres://localhost/db?tls_ca=ca.pem&tls_min_version=1.3", url.Values{
+			"tls_ca":       {"default.pem"},
+			"tls_insecure": {"true"},
+		})
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		pp := p.(*PostgresPinger)
+		if pp.url.String() != "postgres://localhost/db?sslmode=disable" {
+			t.Fatalf("unexpected result: %#v", pp.url.String())
+		}
+
+		if o := pp.tls; !o.set || o.ca != "ca.pem" || o.minVersion != tls.VersionTLS13 || !o.insecure {
+			t.Fatalf("unexpected TLS options: %+#v", o)
+		}
+	})
+
+	t.Run("Check Params", func(t *testing.T) {
+		p, err := Parse("redis://localhost?tls_min_days=7&tls_report=true")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if o := p.(*RedisPinger).tls; o.set || o.minDays != 7 || !o.report {
+			t.Fatalf("unexpected TLS options: %+#v", o)
+		}
+	})
+
+	t.Run("Group", func(t *testing.T) {
+		p, err := ParseWithDefaults("any://?target=redis://localhost", url.Values{"tls_ca": {"ca.pem"}})
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if rp := p.(*GroupPinger).pingers[0].(*RedisPinger); rp.tls.set || rp.tls.ca != "ca.pem" {
+			t.Fatalf("unexpected TLS options: %+#v", rp.tls)
+		}
+	})
+
+	t.Run("No TLS", func(t *testing.T) {
+		// The defaults are ignored by targets without TLS.
+		if _, err := ParseWithDefaults("tcp://localhost:80", url.Values{"tls_ca": {"ca.pem"}}); err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+	})
+
+	for _, c := range []struct {
+		rawurl, msg string
+	}{
+		{"mysql://localhost/?tls_cert=cert.pem", "tls_cert and tls_key must be given together"},
+		{"https://localhost/?tls_min_version=1.4", "invalid tls_min_version: \"1.4\" (must be 1.0, 1.1, 1.2 or 1.3)"},
+		{"redis://localhost/?tls_insecure=yes", "invalid tls_insecure: \"yes\": "},
+		{"postgres://localhost/?sslmode=require&tls_ca=ca.pem", "sslmode cannot be used with tls_* parameters"},
+		{"tcp://localhost:80?tls_ca=ca.pem", "tls_ca cannot be used with tcp URL (no TLS)"},
+		{"udp://localhost:53?tls_insecure=true", "tls_insecure cannot be used with udp URL (no TLS)"},
+		{"icmp://localhost?tls_min_days=7", "tls_min_days cannot be used with icmp URL (no TLS)"},
+		{"dns://localhost/example.com?tls_report=true", "tls_report cannot be used with dns URL (no TLS)"},
+	} {
+		_, err := Parse(c.rawurl)
+		if err == nil {
+			t.Fatalf("succeeded in Parse(): %#v", c.rawurl)
+		}
+
+		if msg := err.Error(); !strings.HasPrefix(msg, c.msg) {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	}
+}
+
+func TestTLSPinger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "png")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := pngtest.NewCA()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, ca.CertPEM, 0600); err != nil {
+		panic(err)
+	}
+
+	s := pngtest.NewTCPServer()
+	defer s.Close()
+	s.SetTLS(ca.ServerConfig(ca.IssueUntil(time.Now().Add(10*24*time.Hour+time.Hour), "127.0.0.1"), false))
+
+	ping := func(params string) (*Report, error) {
+		p, err := Parse("tls://" + s.Addr + "?tls_ca=" + caFile + params)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, report := WithReport(context.Background())
+		return report, p.Ping(ctx)
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		report, err := ping("")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		details := make(map[string]string)
+		for _, d := range report.Details() {
+			details[d.Name] = d.Value
+		}
+
+		if details["tls_subject"] != "CN=pngtest" || details["tls_issuer"] != "CN=pngtest CA" || details["tls_sans"] != "127.0.0.1" {
+			t.Fatalf("unexpected certificate details: %#v", details)
+		}
+
+		if details["tls_version"] != "TLS 1.3" || details["tls_cipher"] == "" {
+			t.Fatalf("unexpected connection details: %#v", details)
+		}
+
+		if details["tls_expiry_days"] != "10" {
+			t.Fatalf("unexpected expiry days: %#v", details["tls_expiry_days"])
+		}
+
+		if warnings := report.Warnings(); len(warnings) != 0 {
+			t.Fatalf("unexpected warnings: %#v", warnings)
+		}
+	})
+
+	t.Run("Warn Days", func(t *testing.T) {
+		report, err := ping("&tls_warn_days=30")
+		if err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		if warnings := report.Warnings(); len(warnings) != 1 || warnings[0] != "certificate \"pngtest\" expires in 10 days (less than 30 days)" {
+			t.Fatalf("unexpected warnings: %#v", warnings)
+		}
+	})
+
+	t.Run("Min Days", func(t *testing.T) {
+		_, err := ping("&tls_min_days=30")
+		if err == nil {
+			t.Fatal("succeeded in p.Ping()")
+		}
+
+		if msg := err.Error(); !strings.HasSuffix(msg, "certificate \"pngtest\" expires in 10 days (less than 30 days)") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		p, err := Parse("tls://" + s.Addr + "?tls_min_days=7&tls_ca=" + caFile)
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		clock := pngtest.NewFakeClock()
+		clock.Add(time.Since(clock.Now()) + 5*24*time.Hour)
+		p.(*TLSPinger).tls.clock = clock
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded in p.Ping()")
+		}
+
+		if msg := err.Error(); !strings.HasSuffix(msg, "certificate \"pngtest\" expires in 5 days (less than 7 days)") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("Parse", func(t *testing.T) {
+		p, err := Parse("tls://localhost")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		if s := Describe(p); s != "tls://localhost:443" {
+			t.Fatalf("unexpected result: %#v", s)
+		}
+
+		if _, err := Parse("tls://localhost?tls_min_days=x"); err == nil || err.Error() != "invalid tls_min_days: \"x\"" {
+			t.Fatalf("unexpected error: %+#v", err)
+		}
+	})
+}
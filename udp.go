@@ -0,0 +1,135 @@
+package png
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// UDPPinger sends a payload in a UDP datagram, and waits for a reply.
+type UDPPinger struct {
+	network string
+	addr    string
+	payload []byte
+	// expect is the pattern of the reply, or nil for any reply.
+	expect *regexp.Regexp
+}
+
+func (p *UDPPinger) String() string {
+	u := &url.URL{Scheme: p.network, Host: p.addr}
+
+	q := url.Values{}
+	if len(p.payload) > 0 {
+		q.Set("payload_hex", hex.EncodeToString(p.payload))
+	}
+	if p.expect != nil {
+		q.Set("expect", p.expect.String())
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
+
+func (p *UDPPinger) Ping(ctx context.Context) error {
+	conn, err := dialContext(ctx, p.network, p.addr)
+	if err != nil {
+		return errors.Wrapf(err, "failed in connecting %s on %s", p.addr, p.network)
+	}
+	defer conn.Close()
+
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	fail := func(err error) error {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return errors.Wrapf(err, "failed in UDP request to %s", p.addr)
+	}
+
+	start := time.Now()
+	if _, err := conn.Write(p.payload); err != nil {
+		return fail(err)
+	}
+
+	buf := make([]byte, 65535)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return fail(err)
+	}
+	reply := buf[:n]
+
+	report(ctx, "udp_rtt", time.Since(start).String())
+	report(ctx, "udp_bytes", strconv.Itoa(n))
+
+	if p.expect != nil && !p.expect.Match(reply) {
+		return errors.Errorf("unexpected UDP reply: %#v", string(reply))
+	}
+	return nil
+}
+
+// parseUDP returns a UDPPinger of u, whose payload is given by `payload` in
+// text with escapes like `\n` and `\x00`, or by `payload_hex`.
+func parseUDP(u *url.URL) (Pinger, error) {
+	if u.Port() == "" {
+		return nil, errors.Errorf("invalid %s URL: %#v (no port)", u.Scheme, u.Host)
+	}
+
+	p := &UDPPinger{network: u.Scheme, addr: u.Host}
+	q := u.Query()
+
+	if _, ok := q["payload"]; ok {
+		if _, ok := q["payload_hex"]; ok {
+			return nil, errors.New("payload and payload_hex cannot be given together")
+		}
+
+		payload, err := unescape(q.Get("payload"))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid payload: %#v", q.Get("payload"))
+		}
+		p.payload = payload
+	}
+
+	if v, ok := q["payload_hex"]; ok {
+		payload, err := hex.DecodeString(v[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid payload_hex: %#v", v[0])
+		}
+		p.payload = payload
+	}
+
+	if v, ok := q["expect"]; ok {
+		expect, err := regexp.Compile(v[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid expect: %#v", v[0])
+		}
+		p.expect = expect
+	}
+
+	return p, nil
+}
+
+// unescape decodes escapes of Go string literals in s, like `\n` and `\x00`.
+func unescape(s string) ([]byte, error) {
+	var b bytes.Buffer
+	for len(s) > 0 {
+		c, multibyte, tail, err := strconv.UnquoteChar(s, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		if multibyte {
+			b.WriteRune(c)
+		} else {
+			b.WriteByte(byte(c))
+		}
+		s = tail
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,142 @@
+package png
+
+import (
+	"testing"
+
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// listenUDPEcho starts a UDP server replying the upper case of requests.
+func listenUDPEcho(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed in net.ListenPacket(): %+#v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			conn.WriteTo([]byte(strings.ToUpper(string(buf[:n]))), addr)
+		}
+	}()
+
+	return conn.LocalAddr().String()
+}
+
+func TestUDPPinger(t *testing.T) {
+	addr := listenUDPEcho(t)
+
+	t.Run("OK", func(t *testing.T) {
+		p, err := Parse("udp://" + addr + "?payload=ping%5Cn&expect=%5EPING%5Cn%24")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, r := WithReport(context.Background())
+		if err := p.Ping(ctx); err != nil {
+			t.Fatalf("failed in p.Ping(): %+#v", err)
+		}
+
+		details := make(map[string]string)
+		for _, d := range r.Details() {
+			details[d.Name] = d.Value
+		}
+		if details["udp_bytes"] != "5" || details["udp_rtt"] == "" {
+			t.Fatalf("unexpected details: %+#v", details)
+		}
+	})
+
+	t.Run("Unexpected Reply", func(t *testing.T) {
+		p, err := Parse("udp://" + addr + "?payload=pong&expect=PING")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		err = p.Ping(context.Background())
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); msg != "unexpected UDP reply: \"PONG\"" {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+
+	t.Run("No Reply", func(t *testing.T) {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("failed in net.ListenPacket(): %+#v", err)
+		}
+		defer conn.Close()
+
+		p, err := Parse("udp://" + conn.LocalAddr().String() + "?payload=ping")
+		if err != nil {
+			t.Fatalf("failed in Parse(): %+#v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		err = p.Ping(ctx)
+		if err == nil {
+			t.Fatal("succeeded to ping")
+		}
+
+		if msg := err.Error(); !strings.HasPrefix(msg, "failed in UDP request to ") {
+			t.Fatalf("unexpected error message: %#v", msg)
+		}
+	})
+}
+
+func TestParseUDPURL(t *testing.T) {
+	for _, c := range []struct {
+		rawurl  string
+		payload string
+		display string
+	}{
+		{"udp://localhost:7", "", "udp://localhost:7"},
+		{"udp://localhost:7?payload=a%5Cx00%5Cn%C3%A9", "a\x00\né", "udp://localhost:7?payload_hex=61000ac3a9"},
+		{"udp4://localhost:7?payload_hex=ff00", "\xff\x00", "udp4://localhost:7?payload_hex=ff00"},
+		{"udp://localhost:7?expect=OK", "", "udp://localhost:7?expect=OK"},
+	} {
+		p, err := Parse(c.rawurl)
+		if err != nil {
+			t.Fatalf("failed in Parse(%#v): %+#v", c.rawurl, err)
+		}
+
+		if payload := string(p.(*UDPPinger).payload); payload != c.payload {
+			t.Fatalf("unexpected payload of %#v: %#v", c.rawurl, payload)
+		}
+		if s := Describe(p); s != c.display {
+			t.Fatalf("unexpected display form of %#v: %#v", c.rawurl, s)
+		}
+	}
+
+	for _, c := range []struct {
+		rawurl string
+		msg    string
+	}{
+		{"udp://localhost", "invalid udp URL: \"localhost\" (no port)"},
+		{"udp://localhost:7?payload=%5Cq", "invalid payload: \"\\\\q\": invalid syntax"},
+		{"udp://localhost:7?payload_hex=0", "invalid payload_hex: \"0\": encoding/hex: odd length hex string"},
+		{"udp://localhost:7?payload=a&payload_hex=61", "payload and payload_hex cannot be given together"},
+		{"udp://localhost:7?expect=(", "invalid expect: \"(\": error parsing regexp: missing closing ): `(`"},
+	} {
+		p, err := Parse(c.rawurl)
+		if err == nil {
+			t.Fatalf("succeeded in Parse(%#v): %#v", c.rawurl, p)
+		}
+
+		if msg := err.Error(); msg != c.msg {
+			t.Fatalf("unexpected error message of %#v: %#v", c.rawurl, msg)
+		}
+	}
+}
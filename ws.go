@@ -12,46 +12,55 @@ import (
 
 type WebSocketPinger struct {
 	url *url.URL
+	tls tlsOptions
+}
+
+func (ws *WebSocketPinger) String() string {
+	port := "80"
+	if ws.url.Scheme == "wss" {
+		port = "443"
+	}
+	return displayURL(ws.url, secret{}, port)
 }
 
 func (ws *WebSocketPinger) Ping(ctx context.Context) error {
-	done := make(chan error)
-
-	go func() {
-		dialer := &websocket.Dialer{
-			NetDial: func(network, addr string) (net.Conn, error) {
-				dialer := &net.Dialer{}
-				if conn, err := dialer.DialContext(ctx, network, addr); err != nil {
-					return nil, err
-				} else {
-					if t, ok := ctx.Deadline(); ok {
-						conn.SetDeadline(t)
-						conn.SetReadDeadline(t)
-						conn.SetWriteDeadline(t)
-					}
-					return conn, err
-				}
-			},
-		}
+	tlsConfig, err := ws.tls.config(ctx, "")
+	if err != nil {
+		return errors.Wrap(err, "failed in opening WebSocket connection")
+	}
 
-		header := http.Header{}
-		header.Add("User-Agent", "png/0.0.0-dev")
-		conn, _, err := dialer.Dial(ws.url.String(), header)
-		if err != nil {
-			done <- errors.Wrap(err, "failed in opening WebSocket connection")
-			return
-		}
-		defer conn.Close()
+	stop := func() {}
+	dialer := &websocket.Dialer{
+		TLSClientConfig: tlsConfig,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := dialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
 
-		// TODO: should does it check resp fileds?
+			stop = closeOnDone(ctx, conn)
+			return conn, nil
+		},
+	}
 
-		done <- nil
-	}()
+	header := http.Header{}
+	header.Add("User-Agent", "png/0.0.0-dev")
+	conn, _, err := dialer.Dial(ws.url.String(), header)
+	stop()
 
-	select {
-	case <-ctx.Done():
+	if ctx.Err() != nil {
+		if conn != nil {
+			conn.Close()
+		}
 		return errors.Wrap(ctx.Err(), "failed in WebSocket ping")
-	case err := <-done:
-		return err
 	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed in opening WebSocket connection")
+	}
+	defer conn.Close()
+
+	// TODO: should does it check resp fileds?
+
+	return nil
 }